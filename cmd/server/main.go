@@ -1,17 +1,27 @@
 package main
 
 import (
+	"context"
 	"log"
-	"time"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
 
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/auth"
 	"github.com/SwanHtetAungPhyo/kyc-api/internal/handler"
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/jobs"
 	"github.com/SwanHtetAungPhyo/kyc-api/internal/repo"
 	"github.com/SwanHtetAungPhyo/kyc-api/internal/service"
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/webhook"
 	"github.com/SwanHtetAungPhyo/kyc-api/pkg/config"
 	"github.com/SwanHtetAungPhyo/kyc-api/pkg/logger"
+	"github.com/SwanHtetAungPhyo/kyc-api/pkg/seal"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/limiter"
+	"github.com/gofiber/fiber/v2/middleware/recover"
 )
 
 func main() {
@@ -21,57 +31,163 @@ func main() {
 	}
 
 	log := logger.NewLogger()
-	log.Info("Starting KYC verification service")
+	log.WithField("environment", cfg.Environment).Info("Starting KYC verification service")
+	settings := config.NewLive(cfg)
 
-	awsRepo, err := repo.NewAWSRepository(
-		cfg.AWS.AccessKeyID,
-		cfg.AWS.SecretAccessKey,
-		cfg.AWS.Region,
-	)
+	var awsRepo repo.AWSRepository
+	switch cfg.Provider.Name {
+	case config.ProviderFake:
+		log.WithField("fixtures_dir", cfg.Provider.FixturesDir).Info("Using fake verification provider")
+		awsRepo, err = repo.NewFakeRepository(cfg.Provider.FixturesDir)
+	default:
+		awsRepo, err = repo.NewAWSRepository(cfg.AWS, cfg.Attempts.Table, cfg.Verifications.Table)
+	}
 	if err != nil {
-		log.WithError(err).Error("Failed to initialize AWS repository")
+		log.WithError(err).Error("Failed to initialize verification provider")
 		return
 	}
 
-	kycService := service.NewKYCService(awsRepo, log)
-	kycHandler := handler.NewKYCHandler(kycService, log)
+	providers := repo.ProvidersFrom(awsRepo)
+	if cfg.Attempts.Store == config.AttemptStoreBolt {
+		attemptStore, err := repo.NewBoltAttemptStore(cfg.Attempts.Path)
+		if err != nil {
+			log.WithError(err).Error("Failed to initialize attempt store")
+			return
+		}
+		defer attemptStore.Close()
+		providers.Attempts = attemptStore
+	}
+	if cfg.Attempts.OnFailure == config.AttemptFailureRetry {
+		retrying := repo.NewRetryingAttemptStore(providers.Attempts, log, cfg.Attempts.Retry)
+		defer retrying.Close()
+		providers.Attempts = retrying
+	}
+
+	if cfg.Verifications.Store == config.AttemptStoreBolt {
+		verificationStore, err := repo.NewBoltVerificationStore(cfg.Verifications.Path)
+		if err != nil {
+			log.WithError(err).Error("Failed to initialize verification store")
+			return
+		}
+		defer verificationStore.Close()
+		providers.Verifications = verificationStore
+	}
+
+	sealer, err := seal.New(cfg.Verifications.EncryptionKey)
+	if err != nil {
+		log.WithError(err).Error("Failed to initialize record encryption")
+		return
+	}
+
+	kycService := service.NewKYCService(providers, log, settings, sealer)
+
+	deliveryLog, err := webhook.NewFileDeliveryLog(cfg.Webhook.LogPath)
+	if err != nil {
+		log.WithError(err).Error("Failed to open webhook delivery log")
+		return
+	}
+	webhooks := webhook.NewDispatcher(cfg, deliveryLog, log)
+	if !webhooks.Enabled() {
+		log.Info("WEBHOOK_SECRET not set, webhook callbacks are disabled")
+	}
+
+	jobStore, err := jobs.NewFileStore(cfg.Jobs.Dir)
+	if err != nil {
+		log.WithError(err).Error("Failed to initialize job store")
+		return
+	}
+
+	jobPool := jobs.NewPool(jobStore, kycService, webhooks, log, cfg)
+	if err := jobPool.Start(context.Background()); err != nil {
+		log.WithError(err).Error("Failed to start job workers")
+		return
+	}
+
+	kycHandler := handler.NewKYCHandler(kycService, jobPool, webhooks, log)
+	keyring, err := auth.NewKeyring(cfg.JWT)
+	if err != nil {
+		log.WithError(err).Error("Failed to load API key signing keys")
+		return
+	}
+	keyStore, err := auth.NewBoltKeyStore(cfg.JWT.KeyStorePath)
+	if err != nil {
+		log.WithError(err).Error("Failed to open API key store")
+		return
+	}
+	defer keyStore.Close()
+
+	apiKeyHandler := handler.NewAPIKeyHandler(keyring, keyStore, webhooks, log, settings)
+	if cfg.JWT.AdminToken == "" {
+		log.Info("ADMIN_TOKEN not set, API key issuance is disabled")
+	}
 
 	app := fiber.New(fiber.Config{
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
 			code := fiber.StatusInternalServerError
+			message := "Internal server error"
 			if e, ok := err.(*fiber.Error); ok {
 				code = e.Code
+				message = e.Message
 			}
 
 			log.WithError(err).Error("Request failed")
 
 			return c.Status(code).JSON(fiber.Map{
 				"success": false,
-				"error":   err.Error(),
+				"error":   message,
 			})
 		},
 	})
 
-	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
-		AllowMethods: "*",
-		AllowHeaders: "*",
-	}))
+	// A panic in a handler fails the request through the error handler
+	// instead of taking the server down
+	app.Use(recover.New(recover.Config{EnableStackTrace: true}))
 
-	app.Use(limiter.New(limiter.Config{
-		Max:        10,
-		Expiration: 1 * time.Minute,
-		KeyGenerator: func(c *fiber.Ctx) string {
-			return c.IP()
-		},
-		LimitReached: func(c *fiber.Ctx) error {
-			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
-				"success": false,
-				"error":   "Rate limit exceeded. Please try again later.",
-			})
-		},
+	// Without allowed origins the middleware is left out entirely, as it
+	// treats an empty origin list as "*"
+	if cfg.Server.CORSOrigins != "" {
+		app.Use(cors.New(cors.Config{
+			AllowOrigins: cfg.Server.CORSOrigins,
+			AllowMethods: "*",
+			AllowHeaders: "*",
+		}))
+	}
+
+	rateLimitReached := func(c *fiber.Ctx) error {
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"success": false,
+			"error":   "Rate limit exceeded. Please try again later.",
+		})
+	}
+
+	// Every route is limited per IP before the API key is checked, so
+	// requests with invalid keys are limited too. KYC routes are also limited
+	// per tenant once the key is verified.
+	app.Use(reloadableLimiter(settings, func(limits config.RateLimitConfig) fiber.Handler {
+		return limiter.New(limiter.Config{
+			Max:        limits.Max,
+			Expiration: limits.Window,
+			KeyGenerator: func(c *fiber.Ctx) string {
+				return c.IP()
+			},
+			LimitReached: rateLimitReached,
+		})
 	}))
 
+	tenantLimiter := reloadableLimiter(settings, func(limits config.RateLimitConfig) fiber.Handler {
+		return limiter.New(limiter.Config{
+			Max:        limits.TenantMax,
+			Expiration: limits.Window,
+			KeyGenerator: func(c *fiber.Ctx) string {
+				if tenantID := handler.TenantID(c); tenantID != "" {
+					return "tenant:" + tenantID
+				}
+				return "ip:" + c.IP()
+			},
+			LimitReached: rateLimitReached,
+		})
+	})
+
 	app.Get("/health", func(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{
 			"status":  "healthy",
@@ -79,7 +195,35 @@ func main() {
 		})
 	})
 
-	kycHandler.RegisterRoutes(app)
+	apiKeyHandler.RegisterRoutes(app)
+	kycHandler.RegisterRoutes(app, apiKeyHandler.JWTMiddleware(), tenantLimiter)
+
+	go func() {
+		hangup := make(chan os.Signal, 1)
+		signal.Notify(hangup, syscall.SIGHUP)
+		for range hangup {
+			ignored, err := settings.Reload()
+			if err != nil {
+				log.WithError(err).Error("Failed to reload configuration, keeping the current one")
+				continue
+			}
+			if len(ignored) > 0 {
+				log.WithField("sections", strings.Join(ignored, ",")).Info("Changes to these settings require a restart and were ignored")
+			}
+			log.Info("Configuration reloaded")
+		}
+	}()
+
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		<-quit
+
+		log.Info("Shutting down server")
+		if err := app.Shutdown(); err != nil {
+			log.WithError(err).Error("Failed to shut down server")
+		}
+	}()
 
 	port := ":" + cfg.Server.Port
 	log.WithField("port", cfg.Server.Port).Info("Server starting")
@@ -87,4 +231,31 @@ func main() {
 	if err := app.Listen(port); err != nil {
 		log.WithError(err).Error("Failed to start server")
 	}
+
+	log.Info("Waiting for verification jobs to finish")
+	jobPool.Stop()
+	webhooks.Close()
+}
+
+// reloadableLimiter builds a limiter from the current rate limits and
+// rebuilds it when a reload changes them. Fiber's limiter has a fixed
+// maximum, so counting starts afresh after such a reload.
+func reloadableLimiter(settings *config.Live, build func(config.RateLimitConfig) fiber.Handler) fiber.Handler {
+	limits := settings.Current().RateLimit
+	var current atomic.Pointer[fiber.Handler]
+	limit := build(limits)
+	current.Store(&limit)
+
+	settings.OnReload(func(cfg *config.Config) {
+		if cfg.RateLimit == limits {
+			return
+		}
+		limits = cfg.RateLimit
+		limit := build(limits)
+		current.Store(&limit)
+	})
+
+	return func(c *fiber.Ctx) error {
+		return (*current.Load())(c)
+	}
 }
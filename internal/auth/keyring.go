@@ -0,0 +1,211 @@
+// Package auth signs and verifies API keys and keeps track of issued keys.
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/SwanHtetAungPhyo/kyc-api/pkg/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// LegacyKeyID verifies tokens that carry no kid header, i.e. tokens issued
+// before key rotation was supported
+const LegacyKeyID = "default"
+
+// signingKey is one key of the ring. signKey is nil for keys that can only
+// verify, e.g. the public half of a retired key.
+type signingKey struct {
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// Keyring holds the keys API keys may be signed with, identified by kid:
+// HMAC secrets and RSA, ECDSA or Ed25519 key pairs. New tokens are signed
+// with the active key; tokens signed with any other key in the ring stay
+// valid until that key is removed.
+type Keyring struct {
+	keys        map[string]signingKey
+	activeKeyID string
+}
+
+// NewKeyring loads the HMAC secrets and PEM key files configured in cfg
+func NewKeyring(cfg config.JWTConfig) (*Keyring, error) {
+	ring := &Keyring{
+		keys:        make(map[string]signingKey),
+		activeKeyID: cfg.ActiveKeyID,
+	}
+
+	for kid, secret := range cfg.Keys {
+		if secret == "" {
+			return nil, fmt.Errorf("signing key %q is empty", kid)
+		}
+		ring.keys[kid] = signingKey{
+			method:    jwt.SigningMethodHS256,
+			signKey:   []byte(secret),
+			verifyKey: []byte(secret),
+		}
+	}
+
+	for kid, path := range cfg.PrivateKeyFiles {
+		if _, exists := ring.keys[kid]; exists {
+			return nil, fmt.Errorf("signing key %q is configured twice", kid)
+		}
+		key, err := loadPrivateKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %q: %w", kid, err)
+		}
+		ring.keys[kid] = key
+	}
+
+	for kid, path := range cfg.PublicKeyFiles {
+		if _, exists := ring.keys[kid]; exists {
+			return nil, fmt.Errorf("signing key %q is configured twice", kid)
+		}
+		key, err := loadPublicKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("verification key %q: %w", kid, err)
+		}
+		ring.keys[kid] = key
+	}
+
+	if len(ring.keys) == 0 {
+		return nil, errors.New("no signing keys configured")
+	}
+	active, ok := ring.keys[ring.activeKeyID]
+	if !ok {
+		return nil, fmt.Errorf("active signing key %q is not configured", ring.activeKeyID)
+	}
+	if active.signKey == nil {
+		return nil, fmt.Errorf("active signing key %q has no private key", ring.activeKeyID)
+	}
+
+	return ring, nil
+}
+
+// ActiveKeyID is the kid new tokens are signed with
+func (k *Keyring) ActiveKeyID() string {
+	return k.activeKeyID
+}
+
+// Sign signs claims with the active key and records its kid in the header
+func (k *Keyring) Sign(claims jwt.Claims) (string, error) {
+	key := k.keys[k.activeKeyID]
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = k.activeKeyID
+	return token.SignedString(key.signKey)
+}
+
+// Parse verifies a token against the key named by its kid header. The token
+// must use that key's algorithm, so a public key can never be used as an
+// HMAC secret.
+func (k *Keyring) Parse(tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			kid = LegacyKeyID
+		}
+		key, ok := k.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return key.verifyKey, nil
+	})
+}
+
+func loadPrivateKey(path string) (signingKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return signingKey{}, err
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return signingKey{}, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return signingKey{}, fmt.Errorf("unsupported private key type %T", parsed)
+	}
+	key, err := keyForPublic(signer.Public())
+	if err != nil {
+		return signingKey{}, err
+	}
+	key.signKey = signer
+	return key, nil
+}
+
+func loadPublicKey(path string) (signingKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return signingKey{}, err
+	}
+
+	var parsed interface{}
+	if block.Type == "RSA PUBLIC KEY" {
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	} else {
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return signingKey{}, fmt.Errorf("failed to parse public key: %w", err)
+	}
+	return keyForPublic(parsed)
+}
+
+// keyForPublic picks the signing method for a public key
+func keyForPublic(public crypto.PublicKey) (signingKey, error) {
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < 2048 {
+			return signingKey{}, fmt.Errorf("RSA key of %d bits is too short, 2048 required", pub.N.BitLen())
+		}
+		return signingKey{method: jwt.SigningMethodRS256, verifyKey: pub}, nil
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			return signingKey{method: jwt.SigningMethodES256, verifyKey: pub}, nil
+		case elliptic.P384():
+			return signingKey{method: jwt.SigningMethodES384, verifyKey: pub}, nil
+		case elliptic.P521():
+			return signingKey{method: jwt.SigningMethodES512, verifyKey: pub}, nil
+		}
+		return signingKey{}, fmt.Errorf("unsupported ECDSA curve %s", pub.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return signingKey{method: jwt.SigningMethodEdDSA, verifyKey: pub}, nil
+	}
+	return signingKey{}, fmt.Errorf("unsupported public key type %T", public)
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s contains no PEM data", path)
+	}
+	return block, nil
+}
@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SwanHtetAungPhyo/kyc-api/pkg/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+// writeKeys writes the PKCS #8 private key and PKIX public key of signer and
+// returns their paths
+func writeKeys(t *testing.T, signer crypto.Signer) (string, string) {
+	t.Helper()
+
+	private, err := x509.MarshalPKCS8PrivateKey(signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	privatePath, publicPath := filepath.Join(dir, "private.pem"), filepath.Join(dir, "public.pem")
+	for path, block := range map[string]*pem.Block{
+		privatePath: {Type: "PRIVATE KEY", Bytes: private},
+		publicPath:  {Type: "PUBLIC KEY", Bytes: public},
+	} {
+		if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return privatePath, publicPath
+}
+
+func TestKeyringSignAndParse(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	edPrivate, _ := writeKeys(t, edKey)
+	ecPrivate, _ := writeKeys(t, ecKey)
+
+	tests := []struct {
+		active string
+		alg    string
+	}{
+		{"hmac", "HS256"},
+		{"ed", "EdDSA"},
+		{"ec", "ES256"},
+	}
+
+	for _, tt := range tests {
+		ring, err := NewKeyring(config.JWTConfig{
+			Keys:            map[string]string{"hmac": testSecret},
+			PrivateKeyFiles: map[string]string{"ed": edPrivate, "ec": ecPrivate},
+			ActiveKeyID:     tt.active,
+		})
+		if err != nil {
+			t.Fatalf("NewKeyring() error = %v", err)
+		}
+
+		signed, err := ring.Sign(jwt.MapClaims{"sub": "acme"})
+		if err != nil {
+			t.Fatalf("Sign() error = %v", err)
+		}
+		token, err := ring.Parse(signed)
+		if err != nil {
+			t.Fatalf("Parse() of a %s token error = %v", tt.active, err)
+		}
+		if token.Header["kid"] != tt.active || token.Method.Alg() != tt.alg {
+			t.Errorf("token kid = %v, alg = %s, want %s, %s", token.Header["kid"], token.Method.Alg(), tt.active, tt.alg)
+		}
+	}
+}
+
+func TestKeyringPinsAlgorithm(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	_, edPublic := writeKeys(t, edKey)
+	publicPEM, err := os.ReadFile(edPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ring, err := NewKeyring(config.JWTConfig{
+		Keys:           map[string]string{"hmac": testSecret},
+		PublicKeyFiles: map[string]string{"ed": edPublic},
+		ActiveKeyID:    "hmac",
+	})
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+
+	// An HMAC token keyed with the published public key must not verify
+	// against the Ed25519 key
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "acme"})
+	forged.Header["kid"] = "ed"
+	for _, secret := range [][]byte{publicPEM, []byte(edKey.Public().(ed25519.PublicKey))} {
+		signed, err := forged.SignedString(secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ring.Parse(signed); err == nil {
+			t.Error("Parse() accepted an HS256 token for an Ed25519 key")
+		}
+	}
+
+	unknown := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "acme"})
+	unknown.Header["kid"] = "retired"
+	signed, _ := unknown.SignedString([]byte(testSecret))
+	if _, err := ring.Parse(signed); err == nil {
+		t.Error("Parse() accepted a token with an unknown kid")
+	}
+}
+
+func TestKeyringLegacyTokens(t *testing.T) {
+	ring, err := NewKeyring(config.JWTConfig{
+		Keys:        map[string]string{LegacyKeyID: testSecret, "next": testSecret + "next"},
+		ActiveKeyID: "next",
+	})
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+
+	signed, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "acme"}).SignedString([]byte(testSecret))
+	if _, err := ring.Parse(signed); err != nil {
+		t.Errorf("Parse() of a token without kid error = %v, want it verified with the %q key", err, LegacyKeyID)
+	}
+}
+
+func TestNewKeyringRejects(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	_, edPublic := writeKeys(t, edKey)
+	shortRSA, _ := rsa.GenerateKey(rand.Reader, 1024)
+	rsaPrivate, _ := writeKeys(t, shortRSA)
+
+	tests := []struct {
+		name string
+		cfg  config.JWTConfig
+	}{
+		{"no keys", config.JWTConfig{ActiveKeyID: "default"}},
+		{"empty secret", config.JWTConfig{Keys: map[string]string{"default": ""}, ActiveKeyID: "default"}},
+		{"unknown active key", config.JWTConfig{Keys: map[string]string{"default": testSecret}, ActiveKeyID: "next"}},
+		{"public key active", config.JWTConfig{PublicKeyFiles: map[string]string{"ed": edPublic}, ActiveKeyID: "ed"}},
+		{"kid configured twice", config.JWTConfig{
+			Keys:           map[string]string{"ed": testSecret},
+			PublicKeyFiles: map[string]string{"ed": edPublic},
+			ActiveKeyID:    "ed",
+		}},
+		{"short RSA key", config.JWTConfig{PrivateKeyFiles: map[string]string{"rsa": rsaPrivate}, ActiveKeyID: "rsa"}},
+		{"missing key file", config.JWTConfig{PrivateKeyFiles: map[string]string{"rsa": filepath.Join(t.TempDir(), "missing.pem")}, ActiveKeyID: "rsa"}},
+	}
+
+	for _, tt := range tests {
+		if _, err := NewKeyring(tt.cfg); err == nil {
+			t.Errorf("NewKeyring() with %s succeeded, want an error", tt.name)
+		}
+	}
+}
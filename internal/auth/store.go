@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/models"
+	"github.com/SwanHtetAungPhyo/kyc-api/pkg/boltdb"
+	bolt "go.etcd.io/bbolt"
+)
+
+var ErrKeyNotFound = errors.New("API key not found")
+
+// KeyStore records issued API keys so they can be listed and revoked
+type KeyStore interface {
+	Save(ctx context.Context, key *models.APIKey) error
+	Get(ctx context.Context, id string) (*models.APIKey, error)
+	// List returns the keys of a tenant, or of every tenant when tenantID is
+	// empty, oldest first
+	List(ctx context.Context, tenantID string) ([]*models.APIKey, error)
+	// Revoke marks a key revoked; revoking a revoked key keeps the original
+	// revocation time
+	Revoke(ctx context.Context, id string, at time.Time) (*models.APIKey, error)
+	IsRevoked(ctx context.Context, id string) (bool, error)
+	Close() error
+}
+
+var apiKeysBucket = []byte("api_keys")
+
+// boltKeyStore keeps API keys in an embedded bbolt database as JSON records
+// keyed by key ID
+type boltKeyStore struct {
+	db *bolt.DB
+}
+
+func NewBoltKeyStore(path string) (KeyStore, error) {
+	db, err := boltdb.Open(path, "key store", apiKeysBucket)
+	if err != nil {
+		return nil, err
+	}
+	return &boltKeyStore{db: db}, nil
+}
+
+func (s *boltKeyStore) Save(ctx context.Context, key *models.APIKey) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return put(tx, key)
+	})
+}
+
+func (s *boltKeyStore) Get(ctx context.Context, id string) (*models.APIKey, error) {
+	var key *models.APIKey
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		key, err = get(tx, id)
+		return err
+	})
+	return key, err
+}
+
+func (s *boltKeyStore) List(ctx context.Context, tenantID string) ([]*models.APIKey, error) {
+	var keys []*models.APIKey
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(apiKeysBucket).ForEach(func(_, data []byte) error {
+			var key models.APIKey
+			if err := json.Unmarshal(data, &key); err != nil {
+				return fmt.Errorf("failed to decode API key: %w", err)
+			}
+			if tenantID == "" || key.TenantID == tenantID {
+				keys = append(keys, &key)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].IssuedAt.Before(keys[j].IssuedAt)
+	})
+	return keys, nil
+}
+
+func (s *boltKeyStore) Revoke(ctx context.Context, id string, at time.Time) (*models.APIKey, error) {
+	var key *models.APIKey
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		key, err = get(tx, id)
+		if err != nil {
+			return err
+		}
+		if key.Revoked() {
+			return nil
+		}
+		key.RevokedAt = &at
+		return put(tx, key)
+	})
+	return key, err
+}
+
+func (s *boltKeyStore) IsRevoked(ctx context.Context, id string) (bool, error) {
+	key, err := s.Get(ctx, id)
+	if errors.Is(err, ErrKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return key.Revoked(), nil
+}
+
+func (s *boltKeyStore) Close() error {
+	return s.db.Close()
+}
+
+func get(tx *bolt.Tx, id string) (*models.APIKey, error) {
+	data := tx.Bucket(apiKeysBucket).Get([]byte(id))
+	if data == nil {
+		return nil, ErrKeyNotFound
+	}
+
+	var key models.APIKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("failed to decode API key: %w", err)
+	}
+	return &key, nil
+}
+
+func put(tx *bolt.Tx, key *models.APIKey) error {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return fmt.Errorf("failed to encode API key: %w", err)
+	}
+	return tx.Bucket(apiKeysBucket).Put([]byte(key.ID), data)
+}
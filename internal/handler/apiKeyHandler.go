@@ -1,35 +1,188 @@
 package handler
 
 import (
+	"crypto/subtle"
+	"errors"
 	"fmt"
+	"regexp"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/auth"
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/models"
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/webhook"
 	"github.com/SwanHtetAungPhyo/kyc-api/pkg/config"
 	"github.com/SwanHtetAungPhyo/kyc-api/pkg/logger"
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 type APIKeyHandler struct {
-	logger    logger.Logger
-	jwtSecret string
+	keyring    *auth.Keyring
+	keys       auth.KeyStore
+	webhooks   *webhook.Dispatcher
+	logger     logger.Logger
+	settings   *config.Live
+	adminToken string
 }
 
-func NewAPIKeyHandler(log logger.Logger, cfg *config.Config) *APIKeyHandler {
+func NewAPIKeyHandler(keyring *auth.Keyring, keys auth.KeyStore, webhooks *webhook.Dispatcher, log logger.Logger, settings *config.Live) *APIKeyHandler {
+	cfg := settings.Current()
 	return &APIKeyHandler{
-		logger:    log,
-		jwtSecret: cfg.JWT.Secret,
+		keyring:    keyring,
+		keys:       keys,
+		webhooks:   webhooks,
+		logger:     log,
+		settings:   settings,
+		adminToken: cfg.JWT.AdminToken,
 	}
 }
 
+// adminTokenHeader carries the admin credential required to issue API keys
+const adminTokenHeader = "X-Admin-Token"
+
+// Keys under which JWTMiddleware stores API key claims in fiber.Ctx.Locals
+const (
+	localCallbackURL = "callback_url"
+	localTenantID    = "tenant_id"
+	localKeyID       = "key_id"
+	localScopes      = "scopes"
+	localProfile     = "profile"
+)
+
+// API key scopes
+const (
+	ScopeVerify = "kyc:verify"
+	ScopeRead   = "kyc:read"
+	ScopeAdmin  = "admin"
+)
+
+var knownScopes = []string{ScopeVerify, ScopeRead, ScopeAdmin}
+
+// defaultScopes are granted to keys issued without explicit scopes and to
+// keys issued before scopes existed
+var defaultScopes = []string{ScopeVerify, ScopeRead}
+
+var tenantIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+type apiKeyRequest struct {
+	TenantID    string   `json:"tenant_id"`
+	Scopes      []string `json:"scopes"`
+	CallbackURL string   `json:"callback_url"`
+	Profile     string   `json:"profile"`
+}
+
+// TenantID returns the tenant of the API key that authenticated the request,
+// or "" for keys issued before tenants existed
+func TenantID(c *fiber.Ctx) string {
+	tenantID, _ := c.Locals(localTenantID).(string)
+	return tenantID
+}
+
+// profileOf returns the verification profile the API key is bound to, if any
+func profileOf(c *fiber.Ctx) string {
+	profile, _ := c.Locals(localProfile).(string)
+	return profile
+}
+
+func scopesOf(c *fiber.Ctx) []string {
+	scopes, _ := c.Locals(localScopes).([]string)
+	return scopes
+}
+
+// GenerateAPIKey issues an API key for a tenant. Requests authorized with the
+// admin token may issue keys for any tenant; requests authorized with an
+// admin-scoped API key only for that key's tenant.
 func (h *APIKeyHandler) GenerateAPIKey(c *fiber.Ctx) error {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"iat": time.Now().Unix(),
-		"exp": time.Now().Add(30 * 24 * time.Hour).Unix(),
-	})
+	var req apiKeyRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"error":   "Invalid request body",
+			})
+		}
+	}
+
+	if isAPIKeyCaller(c) {
+		callerTenant := TenantID(c)
+		if req.TenantID == "" {
+			req.TenantID = callerTenant
+		}
+		if req.TenantID != callerTenant {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"success": false,
+				"error":   "API key cannot issue keys for another tenant",
+			})
+		}
+
+		// Keys bound to a profile can't issue keys that escape it
+		if callerProfile := profileOf(c); callerProfile != "" {
+			if req.Profile == "" {
+				req.Profile = callerProfile
+			}
+			if req.Profile != callerProfile {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+					"success": false,
+					"error":   fmt.Sprintf("API key is bound to verification profile %q", callerProfile),
+				})
+			}
+		}
+	}
 
-	tokenString, err := token.SignedString([]byte(h.jwtSecret))
+	if !tenantIDPattern.MatchString(req.TenantID) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "tenant_id is required and may only contain letters, digits, '-' and '_'",
+		})
+	}
+
+	if len(req.Scopes) == 0 {
+		req.Scopes = defaultScopes
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(knownScopes, scope) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"error":   fmt.Sprintf("Unknown scope %q", scope),
+			})
+		}
+	}
+
+	cfg := h.settings.Current()
+	if req.Profile != "" && !cfg.KYC.HasProfile(req.Profile) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   fmt.Sprintf("Unknown verification profile %q", req.Profile),
+		})
+	}
+
+	keyID := uuid.NewString()
+	issuedAt := time.Now()
+	expiresAt := issuedAt.Add(cfg.JWT.TTL)
+	claims := jwt.MapClaims{
+		"jti":   keyID,
+		"tid":   req.TenantID,
+		"scope": strings.Join(req.Scopes, " "),
+		"iat":   issuedAt.Unix(),
+		"exp":   expiresAt.Unix(),
+	}
+	if req.CallbackURL != "" {
+		if err := h.webhooks.ValidateCallbackURL(req.CallbackURL); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"error":   err.Error(),
+			})
+		}
+		claims["callback_url"] = req.CallbackURL
+	}
+	if req.Profile != "" {
+		claims["profile"] = req.Profile
+	}
+
+	tokenString, err := h.keyring.Sign(claims)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to generate API key")
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -38,36 +191,185 @@ func (h *APIKeyHandler) GenerateAPIKey(c *fiber.Ctx) error {
 		})
 	}
 
+	// Register the key before handing it out so it can always be listed
+	// and revoked
+	key := &models.APIKey{
+		ID:           keyID,
+		TenantID:     req.TenantID,
+		Scopes:       req.Scopes,
+		SigningKeyID: h.keyring.ActiveKeyID(),
+		CallbackURL:  req.CallbackURL,
+		Profile:      req.Profile,
+		IssuedAt:     issuedAt,
+		ExpiresAt:    expiresAt,
+	}
+	if err := h.keys.Save(c.Context(), key); err != nil {
+		h.logger.WithError(err).Error("Failed to register API key")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to generate API key",
+		})
+	}
+
+	h.logger.WithFields(map[string]interface{}{
+		"tenant_id": req.TenantID,
+		"key_id":    keyID,
+		"scopes":    req.Scopes,
+		"profile":   req.Profile,
+	}).Info("API key issued")
+
+	response := fiber.Map{
+		"success":   true,
+		"api_key":   tokenString,
+		"key_id":    keyID,
+		"tenant_id": req.TenantID,
+		"scopes":    req.Scopes,
+		"profile":   req.Profile,
+		"expires":   expiresAt.Format(time.RFC3339),
+	}
+	if h.webhooks.Enabled() {
+		response["webhook_secret"] = h.webhooks.SigningSecret(req.TenantID)
+	}
+	return c.JSON(response)
+}
+
+// GetWebhookSecret returns the secret webhook events of the caller's tenant
+// are signed with. With the admin token it returns that of the tenant_id
+// query parameter, which is empty for keys issued before tenants existed.
+func (h *APIKeyHandler) GetWebhookSecret(c *fiber.Ctx) error {
+	if !h.webhooks.Enabled() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Webhook callbacks are not enabled",
+		})
+	}
+
+	tenantID := c.Query("tenant_id")
+	if isAPIKeyCaller(c) {
+		tenantID = TenantID(c)
+	}
+
 	return c.JSON(fiber.Map{
-		"success": true,
-		"api_key": tokenString,
-		"expires": time.Now().Add(30 * 24 * time.Hour).Format(time.RFC3339),
+		"success":        true,
+		"tenant_id":      tenantID,
+		"webhook_secret": h.webhooks.SigningSecret(tenantID),
 	})
 }
 
+var (
+	errInvalidAPIKey  = errors.New("Invalid or expired API key")
+	errKeyCheckFailed = errors.New("Unable to verify API key, please retry later")
+)
+
 func (h *APIKeyHandler) JWTMiddleware() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		authHeader := c.Get("Authorization")
-		if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+		if err := h.authenticate(c); err != nil {
+			return authError(c, err)
+		}
+		return c.Next()
+	}
+}
+
+// authError responds to a failed authentication. Revocation lookups that
+// fail are reported as unavailable rather than accepting the key.
+func authError(c *fiber.Ctx, err error) error {
+	status := fiber.StatusUnauthorized
+	if errors.Is(err, errKeyCheckFailed) {
+		status = fiber.StatusServiceUnavailable
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"success": false,
+		"error":   err.Error(),
+	})
+}
+
+// authenticate validates the bearer API key, rejects revoked keys and stores
+// the key's claims in Locals. The returned error is safe to show to clients.
+func (h *APIKeyHandler) authenticate(c *fiber.Ctx) error {
+	authHeader := c.Get("Authorization")
+	if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
+		return errors.New("Missing or invalid Authorization header")
+	}
+
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	token, err := h.keyring.Parse(tokenString)
+	if err != nil || !token.Valid {
+		h.logger.WithError(err).Error("Invalid or expired API key")
+		return errInvalidAPIKey
+	}
+
+	claims, _ := token.Claims.(jwt.MapClaims)
+	keyID, _ := claims["jti"].(string)
+	if keyID != "" {
+		revoked, err := h.keys.IsRevoked(c.Context(), keyID)
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to check API key revocation")
+			return errKeyCheckFailed
+		}
+		if revoked {
+			h.logger.WithField("key_id", keyID).Info("Rejected revoked API key")
+			return errInvalidAPIKey
+		}
+	}
+
+	if callbackURL, ok := claims["callback_url"].(string); ok {
+		c.Locals(localCallbackURL, callbackURL)
+	}
+	if profile, ok := claims["profile"].(string); ok {
+		c.Locals(localProfile, profile)
+	}
+	tenantID, _ := claims["tid"].(string)
+	scopes := defaultScopes
+	if scope, ok := claims["scope"].(string); ok {
+		scopes = strings.Fields(scope)
+	}
+	c.Locals(localTenantID, tenantID)
+	c.Locals(localKeyID, keyID)
+	c.Locals(localScopes, scopes)
+
+	return nil
+}
+
+// RequireScope rejects requests whose API key lacks the given scope. It must
+// run after JWTMiddleware.
+func RequireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !slices.Contains(scopesOf(c), scope) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 				"success": false,
-				"error":   "Missing or invalid Authorization header",
+				"error":   fmt.Sprintf("API key lacks the %q scope", scope),
 			})
 		}
+		return c.Next()
+	}
+}
+
+// AdminMiddleware lets through requests carrying the configured admin token
+// in the X-Admin-Token header, or an API key with the admin scope. Admin
+// tokens are rejected when none is configured.
+func (h *APIKeyHandler) AdminMiddleware() fiber.Handler {
+	requireAdminScope := RequireScope(ScopeAdmin)
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	return func(c *fiber.Ctx) error {
+		token := c.Get(adminTokenHeader)
+		if token == "" {
+			if err := h.authenticate(c); err != nil {
+				return authError(c, err)
 			}
-			return []byte(h.jwtSecret), nil
-		})
+			return requireAdminScope(c)
+		}
 
-		if err != nil || !token.Valid {
-			h.logger.WithError(err).Error("Invalid or expired API key")
+		if h.adminToken == "" {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"success": false,
+				"error":   "Admin token authentication is disabled",
+			})
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) != 1 {
+			h.logger.WithField("ip", c.IP()).Error("Rejected API key request with invalid admin token")
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"success": false,
-				"error":   "Invalid or expired API key",
+				"error":   "Invalid admin token",
 			})
 		}
 
@@ -75,6 +377,92 @@ func (h *APIKeyHandler) JWTMiddleware() fiber.Handler {
 	}
 }
 
+// ListAPIKeys lists the issued API keys of the caller's tenant. With the
+// admin token it lists every tenant's keys, optionally filtered by the
+// tenant_id query parameter.
+func (h *APIKeyHandler) ListAPIKeys(c *fiber.Ctx) error {
+	tenantID := c.Query("tenant_id")
+	if isAPIKeyCaller(c) {
+		tenantID = TenantID(c)
+	}
+
+	keys, err := h.keys.List(c.Context(), tenantID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list API keys")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to list API keys",
+		})
+	}
+	if keys == nil {
+		keys = []*models.APIKey{}
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"keys":    keys,
+	})
+}
+
+// RevokeAPIKey revokes an API key by ID. Keys issued before keys were
+// registered can only be revoked with the admin token.
+func (h *APIKeyHandler) RevokeAPIKey(c *fiber.Ctx) error {
+	id := c.Params("id")
+	now := time.Now()
+
+	key, err := h.keys.Get(c.Context(), id)
+	if errors.Is(err, auth.ErrKeyNotFound) && !isAPIKeyCaller(c) {
+		key = &models.APIKey{ID: id}
+		err = h.keys.Save(c.Context(), key)
+	}
+	if errors.Is(err, auth.ErrKeyNotFound) || (err == nil && isAPIKeyCaller(c) && key.TenantID != TenantID(c)) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   "API key not found",
+		})
+	}
+	if err == nil {
+		key, err = h.keys.Revoke(c.Context(), id, now)
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to revoke API key")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to revoke API key",
+		})
+	}
+
+	h.logger.WithFields(map[string]interface{}{
+		"tenant_id": key.TenantID,
+		"key_id":    key.ID,
+	}).Info("API key revoked")
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"key":     key,
+	})
+}
+
+// isAPIKeyCaller reports whether the request was authorized by an API key
+// rather than the admin token
+func isAPIKeyCaller(c *fiber.Ctx) bool {
+	keyID, _ := c.Locals(localKeyID).(string)
+	return keyID != ""
+}
+
+// JWKS publishes the public signing keys so other services can verify API
+// keys without holding a secret
+func (h *APIKeyHandler) JWKS(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(h.keyring.JWKS())
+}
+
 func (h *APIKeyHandler) RegisterRoutes(app *fiber.App) {
-	app.Post("/api-key", h.GenerateAPIKey)
+	app.Get("/.well-known/jwks.json", h.JWKS)
+
+	admin := h.AdminMiddleware()
+	app.Post("/api-key", admin, h.GenerateAPIKey)
+	app.Get("/api-keys", admin, h.ListAPIKeys)
+	app.Delete("/api-keys/:id", admin, h.RevokeAPIKey)
+	app.Get("/webhook-secret", admin, h.GetWebhookSecret)
 }
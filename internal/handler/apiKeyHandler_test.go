@@ -0,0 +1,239 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/auth"
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/models"
+	"github.com/SwanHtetAungPhyo/kyc-api/pkg/config"
+	"github.com/SwanHtetAungPhyo/kyc-api/pkg/logger"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testAdminToken = "test-admin-token"
+
+type apiKeyTest struct {
+	app     *fiber.App
+	keyring *auth.Keyring
+}
+
+// newAPIKeyTest serves the API key routes, plus /verify and /read behind
+// JWTMiddleware and the scope of the same name
+func newAPIKeyTest(t *testing.T, adminToken string) *apiKeyTest {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.JWT.Keys = map[string]string{"k1": "0123456789abcdef0123456789abcdef"}
+	cfg.JWT.ActiveKeyID = "k1"
+	cfg.JWT.AdminToken = adminToken
+
+	keyring, err := auth.NewKeyring(cfg.JWT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := auth.NewBoltKeyStore(filepath.Join(t.TempDir(), "api_keys.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { keys.Close() })
+
+	h := NewAPIKeyHandler(keyring, keys, nil, logger.NewLogger(), config.NewLive(cfg))
+	app := fiber.New()
+	h.RegisterRoutes(app)
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) }
+	app.Get("/verify", h.JWTMiddleware(), RequireScope(ScopeVerify), ok)
+	app.Get("/read", h.JWTMiddleware(), RequireScope(ScopeRead), ok)
+
+	return &apiKeyTest{app: app, keyring: keyring}
+}
+
+// do sends the request with the given headers and decodes the JSON response
+// into out, if set
+func (a *apiKeyTest) do(t *testing.T, method, target, body string, headers map[string]string, out any) int {
+	t.Helper()
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := a.app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// issue has the admin token issue a key and returns the key and its ID
+func (a *apiKeyTest) issue(t *testing.T, body string) (string, string) {
+	t.Helper()
+
+	var issued struct {
+		APIKey string `json:"api_key"`
+		KeyID  string `json:"key_id"`
+	}
+	status := a.do(t, http.MethodPost, "/api-key", body, map[string]string{adminTokenHeader: testAdminToken}, &issued)
+	if status != fiber.StatusOK {
+		t.Fatalf("POST /api-key %s = %d, want 200", body, status)
+	}
+	return issued.APIKey, issued.KeyID
+}
+
+func bearer(apiKey string) map[string]string {
+	return map[string]string{fiber.HeaderAuthorization: "Bearer " + apiKey}
+}
+
+func TestJWTMiddlewareScopes(t *testing.T) {
+	a := newAPIKeyTest(t, testAdminToken)
+	defaultKey, _ := a.issue(t, `{"tenant_id": "acme"}`)
+	readKey, _ := a.issue(t, `{"tenant_id": "acme", "scopes": ["kyc:read"]}`)
+
+	// Keys issued before scopes and key IDs existed get the default scopes
+	legacyKey, err := a.keyring.Sign(jwt.MapClaims{"exp": time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiredKey, err := a.keyring.Sign(jwt.MapClaims{"tid": "acme", "exp": time.Now().Add(-time.Hour).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		target  string
+		headers map[string]string
+		want    int
+	}{
+		{"no key", "/verify", nil, fiber.StatusUnauthorized},
+		{"not a bearer token", "/verify", map[string]string{fiber.HeaderAuthorization: defaultKey}, fiber.StatusUnauthorized},
+		{"malformed key", "/verify", bearer("not-a-jwt"), fiber.StatusUnauthorized},
+		{"expired key", "/verify", bearer(expiredKey), fiber.StatusUnauthorized},
+		{"default scopes verify", "/verify", bearer(defaultKey), fiber.StatusOK},
+		{"default scopes read", "/read", bearer(defaultKey), fiber.StatusOK},
+		{"read scope verify", "/verify", bearer(readKey), fiber.StatusForbidden},
+		{"read scope read", "/read", bearer(readKey), fiber.StatusOK},
+		{"legacy key", "/verify", bearer(legacyKey), fiber.StatusOK},
+		{"default scopes admin", "/api-keys", bearer(defaultKey), fiber.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := a.do(t, http.MethodGet, tt.target, "", tt.headers, nil); status != tt.want {
+				t.Errorf("GET %s = %d, want %d", tt.target, status, tt.want)
+			}
+		})
+	}
+}
+
+func TestJWTMiddlewareRejectsRevokedKey(t *testing.T) {
+	a := newAPIKeyTest(t, testAdminToken)
+	apiKey, keyID := a.issue(t, `{"tenant_id": "acme"}`)
+	otherAdminKey, _ := a.issue(t, `{"tenant_id": "globex", "scopes": ["admin"]}`)
+
+	if status := a.do(t, http.MethodGet, "/verify", "", bearer(apiKey), nil); status != fiber.StatusOK {
+		t.Fatalf("GET /verify before revocation = %d, want 200", status)
+	}
+
+	// Another tenant's admin can't see the key, let alone revoke it
+	if status := a.do(t, http.MethodDelete, "/api-keys/"+keyID, "", bearer(otherAdminKey), nil); status != fiber.StatusNotFound {
+		t.Errorf("DELETE by another tenant = %d, want 404", status)
+	}
+	if status := a.do(t, http.MethodGet, "/verify", "", bearer(apiKey), nil); status != fiber.StatusOK {
+		t.Errorf("GET /verify after another tenant's revocation = %d, want 200", status)
+	}
+
+	if status := a.do(t, http.MethodDelete, "/api-keys/"+keyID, "", map[string]string{adminTokenHeader: testAdminToken}, nil); status != fiber.StatusOK {
+		t.Fatalf("DELETE /api-keys/%s = %d, want 200", keyID, status)
+	}
+	if status := a.do(t, http.MethodGet, "/verify", "", bearer(apiKey), nil); status != fiber.StatusUnauthorized {
+		t.Errorf("GET /verify after revocation = %d, want 401", status)
+	}
+}
+
+func TestAdminMiddleware(t *testing.T) {
+	a := newAPIKeyTest(t, testAdminToken)
+	adminKey, _ := a.issue(t, `{"tenant_id": "acme", "scopes": ["admin"]}`)
+	verifyKey, _ := a.issue(t, `{"tenant_id": "acme"}`)
+	disabled := newAPIKeyTest(t, "")
+
+	tests := []struct {
+		name    string
+		a       *apiKeyTest
+		headers map[string]string
+		want    int
+	}{
+		{"admin token", a, map[string]string{adminTokenHeader: testAdminToken}, fiber.StatusOK},
+		{"wrong admin token", a, map[string]string{adminTokenHeader: "guess"}, fiber.StatusUnauthorized},
+		{"admin token not configured", disabled, map[string]string{adminTokenHeader: testAdminToken}, fiber.StatusForbidden},
+		{"admin scope", a, bearer(adminKey), fiber.StatusOK},
+		{"without admin scope", a, bearer(verifyKey), fiber.StatusForbidden},
+		{"invalid key", a, bearer("not-a-jwt"), fiber.StatusUnauthorized},
+		{"no credentials", a, nil, fiber.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := tt.a.do(t, http.MethodGet, "/api-keys", "", tt.headers, nil); status != tt.want {
+				t.Errorf("GET /api-keys = %d, want %d", status, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIKeysRestrictedToTenant(t *testing.T) {
+	a := newAPIKeyTest(t, testAdminToken)
+	adminKey, _ := a.issue(t, `{"tenant_id": "acme", "scopes": ["admin"]}`)
+	a.issue(t, `{"tenant_id": "acme"}`)
+	a.issue(t, `{"tenant_id": "globex"}`)
+	adminToken := map[string]string{adminTokenHeader: testAdminToken}
+
+	tests := []struct {
+		name    string
+		target  string
+		headers map[string]string
+		want    []string
+	}{
+		{"admin key", "/api-keys", bearer(adminKey), []string{"acme", "acme"}},
+		{"admin key asking for another tenant", "/api-keys?tenant_id=globex", bearer(adminKey), []string{"acme", "acme"}},
+		{"admin token", "/api-keys", adminToken, []string{"acme", "acme", "globex"}},
+		{"admin token filtered", "/api-keys?tenant_id=globex", adminToken, []string{"globex"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body struct {
+				Keys []models.APIKey `json:"keys"`
+			}
+			if status := a.do(t, http.MethodGet, tt.target, "", tt.headers, &body); status != fiber.StatusOK {
+				t.Fatalf("GET %s = %d, want 200", tt.target, status)
+			}
+			var tenants []string
+			for _, key := range body.Keys {
+				tenants = append(tenants, key.TenantID)
+			}
+			if strings.Join(tenants, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("GET %s tenants = %v, want %v", tt.target, tenants, tt.want)
+			}
+		})
+	}
+
+	if status := a.do(t, http.MethodPost, "/api-key", `{"tenant_id": "globex"}`, bearer(adminKey), nil); status != fiber.StatusForbidden {
+		t.Errorf("POST /api-key for another tenant = %d, want 403", status)
+	}
+}
@@ -4,94 +4,181 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/mail"
 	"strings"
 
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/jobs"
 	"github.com/SwanHtetAungPhyo/kyc-api/internal/models"
 	"github.com/SwanHtetAungPhyo/kyc-api/internal/service"
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/webhook"
 	"github.com/SwanHtetAungPhyo/kyc-api/pkg/logger"
 	"github.com/gofiber/fiber/v2"
 )
 
 type KYCHandler struct {
 	kycService service.KYCService
+	jobs       *jobs.Pool
+	webhooks   *webhook.Dispatcher
 	logger     logger.Logger
 }
 
-func NewKYCHandler(kycService service.KYCService, log logger.Logger) *KYCHandler {
+func NewKYCHandler(kycService service.KYCService, jobPool *jobs.Pool, webhooks *webhook.Dispatcher, log logger.Logger) *KYCHandler {
 	return &KYCHandler{
 		kycService: kycService,
+		jobs:       jobPool,
+		webhooks:   webhooks,
 		logger:     log,
 	}
 }
 
 func (h *KYCHandler) HandleKYCVerification(c *fiber.Ctx) error {
-	var req models.KYCRequest
-	if err := c.BodyParser(&req); err != nil {
-		h.logger.WithError(err).Error("Failed to parse request body")
+	req, idBlob, selfieBlob, err := h.readSubmission(c)
+	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(models.KYCResponse{
 			Success: false,
-			Error:   fmt.Sprintf("Failed to parse request body: %v", err),
+			Error:   err.Error(),
 		})
 	}
 
-	if req.Email == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(models.KYCResponse{
-			Success: false,
-			Error:   "Email is required",
-		})
+	result, err := h.kycService.VerifyKYC(c.Context(), idBlob, selfieBlob, req)
+	h.webhooks.Dispatch(req.TenantID, req.CallbackURL, webhook.NewEvent("", req.Email, result, err))
+	if err != nil {
+		return h.handleServiceError(c, err)
 	}
 
-	proceed, err := h.kycService.CheckIfProceed(c.Context(), req.Email)
-	if err != nil {
-		h.logger.WithError(err).Error("Failed to check email status")
+	response := newKYCResponse(result)
+	response.Success = true
+
+	// The response carries the applicant's details, so only its outcome is
+	// logged
+	h.logger.WithFields(map[string]interface{}{
+		"verification_id": response.VerificationID,
+		"verified":        response.Verified,
+		"reason":          response.Reason,
+	}).Info("KYC response sent")
+	return c.JSON(response)
+}
+
+// errorStatus maps service error kinds to HTTP status codes
+var errorStatus = map[service.ErrorKind]int{
+	service.KindValidation:      fiber.StatusUnprocessableEntity,
+	service.KindQualityRejected: fiber.StatusUnprocessableEntity,
+	service.KindDuplicate:       fiber.StatusConflict,
+	service.KindLocked:          fiber.StatusTooManyRequests,
+	service.KindThrottled:       fiber.StatusTooManyRequests,
+	service.KindUpstream:        fiber.StatusBadGateway,
+	service.KindUnavailable:     fiber.StatusServiceUnavailable,
+}
+
+// handleServiceError logs the full error and responds with the status and
+// sanitized message for its kind. Unclassified errors become a generic 500.
+func (h *KYCHandler) handleServiceError(c *fiber.Ctx, err error) error {
+	var svcErr *service.Error
+	if !errors.As(err, &svcErr) {
+		h.logger.WithError(err).Error("KYC verification failed")
 		return c.Status(fiber.StatusInternalServerError).JSON(models.KYCResponse{
 			Success: false,
-			Error:   fmt.Sprintf("Failed to check email status: %v", err),
+			Error:   "Internal server error",
 		})
 	}
-	if proceed {
-		return c.Status(fiber.StatusBadRequest).JSON(models.KYCResponse{
-			Success: false,
-			Error:   "KYC with this email is already done successfully",
-		})
+
+	h.logger.WithError(err).WithField("kind", svcErr.Kind).Error("KYC verification failed")
+
+	status, ok := errorStatus[svcErr.Kind]
+	if !ok {
+		status = fiber.StatusInternalServerError
+	}
+
+	response := newKYCResponse(svcErr.Result)
+	response.Success = false
+	response.Reason = svcErr.Reason
+	response.Message = ""
+	response.Error = svcErr.Message
+
+	return c.Status(status).JSON(response)
+}
+
+// newKYCResponse copies a verification result into the API response shape
+func newKYCResponse(result *models.VerificationResult) models.KYCResponse {
+	if result == nil {
+		return models.KYCResponse{}
+	}
+
+	return models.KYCResponse{
+		VerificationID: result.VerificationID,
+		Profile:        result.Profile,
+		Verified:       result.Verified,
+		Similarity:     result.Similarity,
+		Reason:         result.Reason,
+		Message:        result.Message,
+		Document:       result.Document,
+		Matches:        result.Matches,
+		Suspicious:     result.Suspicious,
+		MRZ:            result.MRZ,
+		Checks:         result.Checks,
+	}
+}
+
+// readSubmission parses the KYC form fields and uploaded images. Errors are
+// suitable for returning to the client as a 400 response.
+func (h *KYCHandler) readSubmission(c *fiber.Ctx) (models.KYCRequest, []byte, []byte, error) {
+	var req models.KYCRequest
+	if err := c.BodyParser(&req); err != nil {
+		h.logger.WithError(err).Error("Failed to parse request body")
+		return req, nil, nil, fmt.Errorf("Failed to parse request body: %v", err)
+	}
+
+	if req.Email == "" {
+		return req, nil, nil, errors.New("Email is required")
+	}
+	if !validEmail(req.Email) {
+		return req, nil, nil, errors.New("Email must be a plain address such as user@example.com")
+	}
+	req.TenantID = TenantID(c)
+	req.SourceIP = c.IP()
+	req.UserAgent = c.Get(fiber.HeaderUserAgent)
+
+	if keyProfile := profileOf(c); keyProfile != "" {
+		if req.Profile != "" && req.Profile != keyProfile {
+			return req, nil, nil, fmt.Errorf("API key is bound to verification profile %q", keyProfile)
+		}
+		req.Profile = keyProfile
+	}
+
+	// A callback registered on the API key applies unless the request
+	// supplies its own
+	if req.CallbackURL == "" {
+		req.CallbackURL, _ = c.Locals(localCallbackURL).(string)
+	}
+	if req.CallbackURL != "" {
+		if !h.webhooks.Enabled() {
+			return req, nil, nil, errors.New("Webhook callbacks are not enabled")
+		}
+		if err := h.webhooks.ValidateCallbackURL(req.CallbackURL); err != nil {
+			return req, nil, nil, err
+		}
 	}
 
 	idBlob, err := h.getFileBlob(c, "id_image")
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to process ID image")
-		return c.Status(fiber.StatusBadRequest).JSON(models.KYCResponse{
-			Success: false,
-			Error:   fmt.Sprintf("Failed to process ID image: %v", err),
-		})
+		return req, nil, nil, fmt.Errorf("Failed to process ID image: %v", err)
 	}
 
 	selfieBlob, err := h.getFileBlob(c, "selfie")
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to process selfie")
-		return c.Status(fiber.StatusBadRequest).JSON(models.KYCResponse{
-			Success: false,
-			Error:   fmt.Sprintf("Failed to process selfie: %v", err),
-		})
-	}
-
-	result, err := h.kycService.VerifyKYC(c.Context(), idBlob, selfieBlob, req.Email)
-	if err != nil {
-		h.logger.WithError(err).Error("KYC verification failed")
-		return c.Status(fiber.StatusBadRequest).JSON(models.KYCResponse{
-			Success: false,
-			Error:   fmt.Sprintf("KYC verification failed: %v", err),
-		})
+		return req, nil, nil, fmt.Errorf("Failed to process selfie: %v", err)
 	}
 
-	response := models.KYCResponse{
-		Success:    true,
-		Verified:   result.Verified,
-		Similarity: result.Similarity,
-		Message:    result.Message,
-	}
+	return req, idBlob, selfieBlob, nil
+}
 
-	h.logger.Info("KYC response sent", "response", response)
-	return c.JSON(response)
+// validEmail reports whether email is a bare address. "#" separates the
+// tenant in attempt keys, so it is not accepted even though RFC 5322 allows it.
+func validEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email && !strings.Contains(email, "#")
 }
 
 func (h *KYCHandler) getFileBlob(c *fiber.Ctx, fieldName string) ([]byte, error) {
@@ -118,6 +205,93 @@ func (h *KYCHandler) getFileBlob(c *fiber.Ctx, fieldName string) ([]byte, error)
 	return blob, nil
 }
 
-func (h *KYCHandler) RegisterRoutes(app *fiber.App) {
-	app.Post("/kyc", h.HandleKYCVerification)
+// HandleSubmitJob accepts a KYC submission for asynchronous processing and
+// responds with 202 and the job ID to poll
+func (h *KYCHandler) HandleSubmitJob(c *fiber.Ctx) error {
+	req, idBlob, selfieBlob, err := h.readSubmission(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.JobResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	job, err := h.jobs.Submit(c.Context(), req, idBlob, selfieBlob)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to submit verification job")
+		status := fiber.StatusInternalServerError
+		message := "Failed to submit verification job"
+		if errors.Is(err, jobs.ErrQueueFull) || errors.Is(err, jobs.ErrPoolClosed) {
+			status = fiber.StatusServiceUnavailable
+			message = "Verification queue is full, please retry later"
+		}
+		return c.Status(status).JSON(models.JobResponse{
+			Success: false,
+			Error:   message,
+		})
+	}
+
+	response := newJobResponse(job)
+	c.Location(response.StatusURL)
+	return c.Status(fiber.StatusAccepted).JSON(response)
+}
+
+// HandleGetJob returns the status of a verification job, and its result
+// once it has finished
+func (h *KYCHandler) HandleGetJob(c *fiber.Ctx) error {
+	job, err := h.jobs.Get(c.Context(), c.Params("id"))
+	// Jobs of other tenants are reported as missing so their IDs leak nothing
+	if err == nil && job.Request.TenantID != TenantID(c) {
+		err = jobs.ErrJobNotFound
+	}
+	if errors.Is(err, jobs.ErrJobNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(models.JobResponse{
+			Success: false,
+			Error:   "Job not found",
+		})
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to load verification job")
+		return c.Status(fiber.StatusInternalServerError).JSON(models.JobResponse{
+			Success: false,
+			Error:   "Failed to load verification job",
+		})
+	}
+
+	return c.JSON(newJobResponse(job))
+}
+
+func newJobResponse(job *models.Job) models.JobResponse {
+	response := models.JobResponse{
+		Success:   true,
+		JobID:     job.ID,
+		Status:    job.Status,
+		StatusURL: "/kyc/jobs/" + job.ID,
+		CreatedAt: job.CreatedAt,
+		UpdatedAt: job.UpdatedAt,
+		Error:     job.Error,
+	}
+
+	if job.Done() {
+		result := newKYCResponse(job.Result)
+		result.Success = job.Status == models.JobCompleted
+		if !result.Success {
+			result.Reason = job.Reason
+			result.Message = ""
+			result.Error = job.Error
+		}
+		response.Result = &result
+	}
+
+	return response
+}
+
+// RegisterRoutes registers the KYC routes behind the given authentication
+// middleware, followed by limit which runs once the tenant is known
+func (h *KYCHandler) RegisterRoutes(app *fiber.App, auth, limit fiber.Handler) {
+	app.Post("/kyc", auth, RequireScope(ScopeVerify), limit, h.HandleKYCVerification)
+	app.Post("/kyc/jobs", auth, RequireScope(ScopeVerify), limit, h.HandleSubmitJob)
+	app.Get("/kyc/jobs/:id", auth, RequireScope(ScopeRead), limit, h.HandleGetJob)
+	app.Get("/kyc/verifications", auth, RequireScope(ScopeRead), limit, h.HandleListVerifications)
+	app.Get("/kyc/verifications/:id", auth, RequireScope(ScopeRead), limit, h.HandleGetVerification)
 }
@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/models"
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/service"
+	"github.com/SwanHtetAungPhyo/kyc-api/pkg/logger"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestValidEmail(t *testing.T) {
+	tests := []struct {
+		email string
+		want  bool
+	}{
+		{"jane@example.com", true},
+		{"jane.doe+kyc@mail.example.co.uk", true},
+		{"jane", false},
+		{"@example.com", false},
+		{"Jane <jane@example.com>", false},
+		{" jane@example.com", false},
+		{"acme#jane@example.com", false},
+	}
+
+	for _, tt := range tests {
+		if got := validEmail(tt.email); got != tt.want {
+			t.Errorf("validEmail(%q) = %v, want %v", tt.email, got, tt.want)
+		}
+	}
+}
+
+func TestHandleServiceError(t *testing.T) {
+	internal := errors.New("dynamodb: table kyc-attempts not found")
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"validation", &service.Error{Kind: service.KindValidation, Message: "m", Err: internal}, fiber.StatusUnprocessableEntity},
+		{"quality rejected", &service.Error{Kind: service.KindQualityRejected, Message: "m", Err: internal}, fiber.StatusUnprocessableEntity},
+		{"duplicate", &service.Error{Kind: service.KindDuplicate, Message: "m", Err: internal}, fiber.StatusConflict},
+		{"locked", &service.Error{Kind: service.KindLocked, Message: "m", Err: internal}, fiber.StatusTooManyRequests},
+		{"throttled", &service.Error{Kind: service.KindThrottled, Message: "m", Err: internal}, fiber.StatusTooManyRequests},
+		{"upstream", &service.Error{Kind: service.KindUpstream, Message: "m", Err: internal}, fiber.StatusBadGateway},
+		{"unavailable", &service.Error{Kind: service.KindUnavailable, Message: "m", Err: internal}, fiber.StatusServiceUnavailable},
+		{"wrapped", fmt.Errorf("verify: %w", &service.Error{Kind: service.KindDuplicate, Message: "m"}), fiber.StatusConflict},
+		{"unknown kind", &service.Error{Kind: "novel", Message: "m", Err: internal}, fiber.StatusInternalServerError},
+		{"unclassified", internal, fiber.StatusInternalServerError},
+	}
+
+	h := &KYCHandler{logger: logger.NewLogger()}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/", func(c *fiber.Ctx) error { return h.handleServiceError(c, tt.err) })
+
+			resp, err := app.Test(httptest.NewRequest("GET", "/", nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+			var body models.KYCResponse
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Success || strings.Contains(body.Error, "dynamodb") {
+				t.Errorf("response = %+v, want a failure without the internal error", body)
+			}
+		})
+	}
+}
+
+func TestErrorStatusCoversEveryKind(t *testing.T) {
+	kinds := []service.ErrorKind{
+		service.KindValidation,
+		service.KindQualityRejected,
+		service.KindDuplicate,
+		service.KindLocked,
+		service.KindThrottled,
+		service.KindUnavailable,
+		service.KindUpstream,
+	}
+	for _, kind := range kinds {
+		if _, ok := errorStatus[kind]; !ok {
+			t.Errorf("errorStatus has no status for %q", kind)
+		}
+	}
+	if len(errorStatus) != len(kinds) {
+		t.Errorf("errorStatus maps %d kinds, want %d", len(errorStatus), len(kinds))
+	}
+}
+
+// profileService records the profile each verification runs under
+type profileService struct {
+	service.KYCService
+	profile string
+}
+
+func (s *profileService) VerifyKYC(ctx context.Context, idBlob, selfieBlob []byte, req models.KYCRequest) (*models.VerificationResult, error) {
+	s.profile = req.Profile
+	return &models.VerificationResult{Profile: req.Profile, Verified: true}, nil
+}
+
+// kycForm builds a KYC submission choosing profile, if set
+func kycForm(t *testing.T, profile string) (*bytes.Buffer, string) {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("email", "jane@example.com")
+	if profile != "" {
+		form.WriteField("profile", profile)
+	}
+	for _, field := range []string{"id_image", "selfie"} {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name=%q; filename="%s.jpg"`, field, field))
+		header.Set("Content-Type", "image/jpeg")
+		part, err := form.CreatePart(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write([]byte("image"))
+	}
+	if err := form.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &body, form.FormDataContentType()
+}
+
+func TestHandleKYCVerificationProfile(t *testing.T) {
+	tests := []struct {
+		name       string
+		keyProfile string
+		profile    string
+		wantStatus int
+		want       string
+	}{
+		{"none", "", "", fiber.StatusOK, ""},
+		{"chosen by request", "", "lenient", fiber.StatusOK, "lenient"},
+		{"bound to key", "strict", "", fiber.StatusOK, "strict"},
+		{"same as key", "strict", "strict", fiber.StatusOK, "strict"},
+		{"other than key", "strict", "lenient", fiber.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &profileService{}
+			h := &KYCHandler{kycService: svc, logger: logger.NewLogger()}
+			app := fiber.New()
+			app.Post("/kyc", func(c *fiber.Ctx) error {
+				if tt.keyProfile != "" {
+					c.Locals(localProfile, tt.keyProfile)
+				}
+				return c.Next()
+			}, h.HandleKYCVerification)
+
+			body, contentType := kycForm(t, tt.profile)
+			req := httptest.NewRequest("POST", "/kyc", body)
+			req.Header.Set("Content-Type", contentType)
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if svc.profile != tt.want {
+				t.Errorf("verified under profile %q, want %q", svc.profile, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/models"
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/repo"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultVerificationPageSize = 20
+	maxVerificationPageSize     = 100
+)
+
+// HandleGetVerification returns a verification record of the caller's
+// tenant
+func (h *KYCHandler) HandleGetVerification(c *fiber.Ctx) error {
+	view, err := h.kycService.GetVerification(c.Context(), TenantID(c), c.Params("id"))
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to load verification record")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to load verification record",
+		})
+	}
+	// Records of other tenants are reported as missing like unknown IDs
+	if view == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   "Verification not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success":      true,
+		"verification": view,
+	})
+}
+
+// HandleListVerifications lists the verification records of the caller's
+// tenant, newest first, filtered by the email, status, from and to query
+// parameters. Pages hold up to limit records; next_cursor is passed as the
+// cursor parameter to fetch the next page.
+func (h *KYCHandler) HandleListVerifications(c *fiber.Ctx) error {
+	query, err := readVerificationQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+
+	views, next, err := h.kycService.ListVerifications(c.Context(), query)
+	if errors.Is(err, repo.ErrInvalidCursor) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invalid cursor",
+		})
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list verification records")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to list verification records",
+		})
+	}
+
+	response := fiber.Map{
+		"success":       true,
+		"verifications": views,
+	}
+	if next != "" {
+		response["next_cursor"] = next
+	}
+	return c.JSON(response)
+}
+
+// readVerificationQuery parses the list parameters. Errors are suitable for
+// returning to the client as a 400 response.
+func readVerificationQuery(c *fiber.Ctx) (repo.VerificationQuery, error) {
+	query := repo.VerificationQuery{
+		TenantID: TenantID(c),
+		Email:    c.Query("email"),
+		Status:   models.VerificationStatus(c.Query("status")),
+		Limit:    c.QueryInt("limit", defaultVerificationPageSize),
+		Cursor:   c.Query("cursor"),
+	}
+
+	switch query.Status {
+	case "", models.VerificationVerified, models.VerificationUnverified, models.VerificationRejected, models.VerificationError:
+	default:
+		return query, fmt.Errorf("Invalid status %q: expected verified, unverified, rejected or error", query.Status)
+	}
+	if query.Limit < 1 || query.Limit > maxVerificationPageSize {
+		return query, fmt.Errorf("Limit must be between 1 and %d", maxVerificationPageSize)
+	}
+
+	var err error
+	if query.From, err = parseTimeParam(c, "from"); err != nil {
+		return query, err
+	}
+	if query.To, err = parseTimeParam(c, "to"); err != nil {
+		return query, err
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		return query, errors.New("From must be before to")
+	}
+	return query, nil
+}
+
+// parseTimeParam reads an RFC 3339 time or a date, which stands for the
+// start of that day in UTC
+func parseTimeParam(c *fiber.Ctx, name string) (time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("Invalid %s %q: expected an RFC 3339 time or a YYYY-MM-DD date", name, value)
+}
@@ -0,0 +1,296 @@
+// Package jobs runs KYC verifications asynchronously on a bounded worker pool.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/models"
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/service"
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/webhook"
+	"github.com/SwanHtetAungPhyo/kyc-api/pkg/config"
+	"github.com/SwanHtetAungPhyo/kyc-api/pkg/logger"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrQueueFull  = errors.New("job queue is full")
+	ErrPoolClosed = errors.New("job pool is shut down")
+
+	errJobPanicked = errors.New("verification job panicked")
+)
+
+// Pool accepts verification jobs, persists them and processes them in the
+// background with a fixed number of workers
+type Pool struct {
+	store      Store
+	kycService service.KYCService
+	webhooks   *webhook.Dispatcher
+	logger     logger.Logger
+	workers    int
+	timeout    time.Duration
+	retention  time.Duration
+
+	queue    chan string
+	stopping chan struct{}
+	wg       sync.WaitGroup
+	requeue  sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+func NewPool(store Store, kycService service.KYCService, webhooks *webhook.Dispatcher, log logger.Logger, cfg *config.Config) *Pool {
+	return &Pool{
+		store:      store,
+		kycService: kycService,
+		webhooks:   webhooks,
+		logger:     log,
+		workers:    max(cfg.Jobs.Workers, 1),
+		timeout:    cfg.Jobs.Timeout,
+		retention:  cfg.Jobs.Retention,
+		queue:      make(chan string, max(cfg.Jobs.QueueSize, 1)),
+		stopping:   make(chan struct{}),
+	}
+}
+
+// Start launches the workers and requeues jobs left unfinished by a previous
+// run. Workers exit once Stop is called and the queue has drained.
+func (p *Pool) Start(ctx context.Context) error {
+	for i := 0; i < p.workers; i++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+
+	if p.retention > 0 {
+		p.wg.Add(1)
+		go p.sweep()
+	}
+
+	unfinished, err := p.store.Unfinished(ctx)
+	if err != nil {
+		return err
+	}
+	if len(unfinished) == 0 {
+		return nil
+	}
+
+	p.logger.WithField("count", len(unfinished)).Info("Requeueing unfinished jobs")
+	p.requeue.Add(1)
+	go func() {
+		defer p.requeue.Done()
+		for _, job := range unfinished {
+			select {
+			case p.queue <- job.ID:
+			case <-p.stopping:
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stop stops accepting work and waits for queued jobs to finish. Jobs that
+// were not queued yet remain pending and are picked up on the next Start.
+func (p *Pool) Stop() {
+	p.mu.Lock()
+	p.closed = true
+	close(p.stopping)
+	p.mu.Unlock()
+
+	p.requeue.Wait()
+	close(p.queue)
+	p.wg.Wait()
+}
+
+// Submit persists a new job and queues it for processing
+func (p *Pool) Submit(ctx context.Context, req models.KYCRequest, idBlob, selfieBlob []byte) (*models.Job, error) {
+	now := time.Now()
+	job := &models.Job{
+		ID:        uuid.NewString(),
+		Status:    models.JobPending,
+		Request:   req,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return nil, ErrPoolClosed
+	}
+
+	if err := p.store.Create(ctx, job, idBlob, selfieBlob); err != nil {
+		return nil, err
+	}
+
+	select {
+	case p.queue <- job.ID:
+	default:
+		job.Error = ErrQueueFull.Error()
+		p.finish(ctx, job, models.JobFailed)
+		return nil, ErrQueueFull
+	}
+
+	p.logger.WithField("job_id", job.ID).Info("Verification job queued")
+
+	return job, nil
+}
+
+func (p *Pool) Get(ctx context.Context, id string) (*models.Job, error) {
+	return p.store.Get(ctx, id)
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+	for id := range p.queue {
+		p.process(id)
+	}
+}
+
+func (p *Pool) process(id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	log := p.logger.WithField("job_id", id)
+
+	job, err := p.store.Get(ctx, id)
+	if err != nil {
+		log.WithError(err).Error("Failed to load job")
+		return
+	}
+	if job.Done() {
+		return
+	}
+
+	// A panic fails the job rather than the server. The job is stored as
+	// failed so the next start does not run it again.
+	defer func() {
+		if r := recover(); r != nil {
+			log.WithField("panic", r).WithField("stack", string(debug.Stack())).Error("Verification job panicked")
+			if job.Done() {
+				return
+			}
+			job.Result = nil
+			job.Error = "Internal server error"
+			p.finish(ctx, job, models.JobFailed)
+			p.webhooks.Dispatch(job.Request.TenantID, job.Request.CallbackURL, webhook.NewEvent(job.ID, job.Request.Email, nil, errJobPanicked))
+		}
+	}()
+
+	job.Status = models.JobRunning
+	job.UpdatedAt = time.Now()
+	if err := p.store.Update(ctx, job); err != nil {
+		log.WithError(err).Error("Failed to mark job running")
+		return
+	}
+
+	idBlob, selfieBlob, err := p.store.LoadImages(ctx, id)
+	if err != nil {
+		log.WithError(err).Error("Failed to load job images")
+		job.Error = "Uploaded images are no longer available"
+		p.finish(ctx, job, models.JobFailed)
+		return
+	}
+
+	// Keying the reservation by job lets a job that was running when the
+	// server stopped take over the identity it still holds
+	req := job.Request
+	req.ReservationID = job.ID
+	result, err := p.kycService.VerifyKYC(ctx, idBlob, selfieBlob, req)
+	if err != nil {
+		log.WithError(err).Error("Verification job failed")
+		applyError(job, err)
+		p.finish(ctx, job, models.JobFailed)
+	} else {
+		job.Result = result
+		p.finish(ctx, job, models.JobCompleted)
+		log.WithField("verified", result.Verified).Info("Verification job completed")
+	}
+
+	// Notify only once the job record is final so receivers that poll the
+	// job after the callback see the same outcome
+	p.webhooks.Dispatch(job.Request.TenantID, job.Request.CallbackURL, webhook.NewEvent(job.ID, job.Request.Email, result, err))
+}
+
+// finish stores the final job state and discards the uploaded images. The
+// applicant's details are left out of the stored job, see redact.
+func (p *Pool) finish(ctx context.Context, job *models.Job, status models.JobStatus) {
+	job.Status = status
+	job.UpdatedAt = time.Now()
+
+	log := p.logger.WithField("job_id", job.ID)
+	if err := p.store.Update(ctx, redact(job)); err != nil {
+		log.WithError(err).Error("Failed to store job result")
+	}
+	if err := p.store.DeleteImages(ctx, job.ID); err != nil {
+		log.WithError(err).Error("Failed to delete job images")
+	}
+}
+
+// redact returns a copy of a finished job without the applicant's email,
+// name and date of birth and without the document read from the ID. Those
+// are only kept in the verification record, with the identifying document
+// fields sealed, which the result's verification_id refers to. The webhook
+// is sent from the unredacted job, so nothing reads them back.
+func redact(job *models.Job) *models.Job {
+	redacted := *job
+	redacted.Request.Email = ""
+	redacted.Request.FullName = ""
+	redacted.Request.DateOfBirth = ""
+	if job.Result == nil {
+		return &redacted
+	}
+
+	result := *job.Result
+	result.Document = nil
+	result.Matches = nil
+	for _, match := range job.Result.Matches {
+		match.Supplied, match.Extracted = "", ""
+		result.Matches = append(result.Matches, match)
+	}
+	redacted.Result = &result
+	return &redacted
+}
+
+// sweep deletes finished jobs once they are older than the retention period,
+// checking at least hourly until the pool is stopped
+func (p *Pool) sweep() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(min(p.retention, time.Hour))
+	defer ticker.Stop()
+	for {
+		deleted, err := p.store.DeleteFinished(context.Background(), time.Now().Add(-p.retention))
+		if err != nil {
+			p.logger.WithError(err).Error("Failed to delete expired jobs")
+		} else if deleted > 0 {
+			p.logger.WithField("count", deleted).Info("Deleted expired jobs")
+		}
+
+		select {
+		case <-ticker.C:
+		case <-p.stopping:
+			return
+		}
+	}
+}
+
+// applyError records a verification failure on the job using only the
+// sanitized details of a service error
+func applyError(job *models.Job, err error) {
+	var svcErr *service.Error
+	if !errors.As(err, &svcErr) {
+		job.Error = "Internal server error"
+		return
+	}
+
+	job.ErrorKind = string(svcErr.Kind)
+	job.Reason = svcErr.Reason
+	job.Error = svcErr.Message
+	job.Result = svcErr.Result
+}
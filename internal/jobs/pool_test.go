@@ -0,0 +1,206 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/models"
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/repo"
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/service"
+	"github.com/SwanHtetAungPhyo/kyc-api/pkg/config"
+	"github.com/SwanHtetAungPhyo/kyc-api/pkg/logger"
+	"github.com/SwanHtetAungPhyo/kyc-api/pkg/seal"
+)
+
+// stubService returns result, or panics when panics is set
+type stubService struct {
+	service.KYCService
+	result *models.VerificationResult
+	panics bool
+}
+
+func (s *stubService) VerifyKYC(ctx context.Context, idBlob, selfieBlob []byte, req models.KYCRequest) (*models.VerificationResult, error) {
+	if s.panics {
+		panic("index out of range")
+	}
+	return s.result, nil
+}
+
+func runJob(t *testing.T, store Store, svc service.KYCService, req models.KYCRequest) *models.Job {
+	t.Helper()
+
+	cfg := config.Default()
+	pool := NewPool(store, svc, nil, logger.NewLogger(), cfg)
+	if err := pool.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	job, err := pool.Submit(context.Background(), req, []byte("id"), []byte("selfie"))
+	if err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	pool.Stop()
+
+	job, err = store.Get(context.Background(), job.ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	return job
+}
+
+func TestPoolFailsPanickingJob(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job := runJob(t, store, &stubService{panics: true}, models.KYCRequest{Email: "a@example.com"})
+	if job.Status != models.JobFailed {
+		t.Errorf("Status = %q, want %q", job.Status, models.JobFailed)
+	}
+	if job.Error != "Internal server error" {
+		t.Errorf("Error = %q, want the generic error", job.Error)
+	}
+
+	if _, _, err := store.LoadImages(context.Background(), job.ID); err == nil {
+		t.Error("LoadImages() succeeded, want the images of the failed job deleted")
+	}
+	unfinished, err := store.Unfinished(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unfinished) != 0 {
+		t.Errorf("Unfinished() = %d jobs, want none so the job is not run again", len(unfinished))
+	}
+}
+
+func TestPoolCompletesJob(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := &models.VerificationResult{
+		VerificationID: "v1",
+		Verified:       true,
+		Document:       &models.IdentityDocument{LastName: &models.DocumentField{Value: "SMITH"}},
+		Matches: []models.FieldMatch{
+			{Field: "full_name", Supplied: "John Smith", Extracted: "JOHN SMITH", Score: 1, Matched: true},
+		},
+	}
+	job := runJob(t, store, &stubService{result: result}, models.KYCRequest{
+		Email:       "a@example.com",
+		FullName:    "John Smith",
+		DateOfBirth: "1990-01-02",
+	})
+	if job.Status != models.JobCompleted {
+		t.Errorf("Status = %q, want %q", job.Status, models.JobCompleted)
+	}
+	if job.Result == nil || job.Result.VerificationID != "v1" || !job.Result.Verified {
+		t.Errorf("Result = %+v, want the verification result", job.Result)
+	}
+	if job.UpdatedAt.Before(job.CreatedAt) || time.Since(job.UpdatedAt) > time.Minute {
+		t.Errorf("UpdatedAt = %v, want the time the job finished", job.UpdatedAt)
+	}
+	if job.Result != nil && job.Result.Document != nil {
+		t.Error("Result.Document is stored, want the document left out of the job")
+	}
+	if job.Result != nil && (len(job.Result.Matches) != 1 || job.Result.Matches[0] != (models.FieldMatch{Field: "full_name", Score: 1, Matched: true})) {
+		t.Errorf("Result.Matches = %+v, want the outcome without the compared values", job.Result.Matches)
+	}
+	if job.Request.Email != "" || job.Request.FullName != "" || job.Request.DateOfBirth != "" {
+		t.Errorf("Request = %+v, want the applicant's email, name and date of birth left out", job.Request)
+	}
+}
+
+// TestPoolResumesJobHoldingReservation restarts the pool after a crash that
+// left a job running with the identity still reserved under its ID
+func TestPoolResumesJobHoldingReservation(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake, err := repo.NewFakeRepository("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealer, err := seal.New("test secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Default()
+	req := models.KYCRequest{Email: "a@example.com", TenantID: "t1"}
+	job := &models.Job{ID: "job-1", Status: models.JobRunning, Request: req, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := store.Create(ctx, job, []byte("id"), []byte("selfie")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fake.Reserve(ctx, repo.AttemptKey(req.TenantID, req.Email), job.ID, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := service.NewKYCService(repo.ProvidersFrom(fake), logger.NewLogger(), config.NewLive(cfg), sealer)
+	pool := NewPool(store, svc, nil, logger.NewLogger(), cfg)
+	if err := pool.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer pool.Stop()
+
+	// The job is requeued in the background, so wait for it to finish
+	deadline := time.Now().Add(5 * time.Second)
+	for !job.Done() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		if job, err = store.Get(ctx, job.ID); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+	}
+	if job.Status != models.JobCompleted || job.Result == nil || !job.Result.Verified {
+		t.Fatalf("job = %s %q %+v, want it completed and verified", job.Status, job.Reason, job.Result)
+	}
+
+	record, err := fake.GetAttempts(ctx, repo.AttemptKey(req.TenantID, req.Email))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record == nil || !record.Processed || record.PendingID != "" {
+		t.Errorf("GetAttempts() = %+v, want the identity verified and its reservation released", record)
+	}
+}
+
+func TestFileStoreDeleteFinished(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	jobs := []*models.Job{
+		{ID: "old-completed", Status: models.JobCompleted, UpdatedAt: now.Add(-48 * time.Hour)},
+		{ID: "old-failed", Status: models.JobFailed, UpdatedAt: now.Add(-48 * time.Hour)},
+		{ID: "old-pending", Status: models.JobPending, UpdatedAt: now.Add(-48 * time.Hour)},
+		{ID: "recent-completed", Status: models.JobCompleted, UpdatedAt: now},
+	}
+	for _, job := range jobs {
+		if err := store.Create(ctx, job, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deleted, err := store.DeleteFinished(ctx, now.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatalf("DeleteFinished() error = %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("DeleteFinished() = %d, want 2", deleted)
+	}
+
+	for _, job := range jobs {
+		_, err := store.Get(ctx, job.ID)
+		wantDeleted := job.ID == "old-completed" || job.ID == "old-failed"
+		if gotDeleted := err == ErrJobNotFound; gotDeleted != wantDeleted {
+			t.Errorf("job %s deleted = %v, want %v", job.ID, gotDeleted, wantDeleted)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/models"
+)
+
+var ErrJobNotFound = errors.New("job not found")
+
+// Store persists verification jobs and the images uploaded with them
+type Store interface {
+	Create(ctx context.Context, job *models.Job, idBlob, selfieBlob []byte) error
+	Get(ctx context.Context, id string) (*models.Job, error)
+	Update(ctx context.Context, job *models.Job) error
+	LoadImages(ctx context.Context, id string) (idBlob, selfieBlob []byte, err error)
+	// DeleteImages removes the uploaded images once a job has been processed
+	DeleteImages(ctx context.Context, id string) error
+	// Unfinished returns the jobs that are pending or were interrupted while
+	// running
+	Unfinished(ctx context.Context) ([]*models.Job, error)
+	// DeleteFinished removes the jobs that finished before the given time and
+	// returns how many were removed
+	DeleteFinished(ctx context.Context, before time.Time) (int, error)
+}
+
+const (
+	jobFile    = "job.json"
+	idFile     = "id_image"
+	selfieFile = "selfie"
+)
+
+// fileStore keeps each job in its own directory as a JSON record plus the
+// raw image files
+type fileStore struct {
+	dir string
+	mu  sync.RWMutex
+}
+
+func NewFileStore(dir string) (Store, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create job directory: %w", err)
+	}
+	return &fileStore{dir: dir}, nil
+}
+
+func (s *fileStore) Create(ctx context.Context, job *models.Job, idBlob, selfieBlob []byte) error {
+	jobDir, err := s.jobDir(job.ID)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Mkdir(jobDir, 0o700); err != nil {
+		return fmt.Errorf("failed to create job: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(jobDir, idFile), idBlob, 0o600); err != nil {
+		return fmt.Errorf("failed to store ID image: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(jobDir, selfieFile), selfieBlob, 0o600); err != nil {
+		return fmt.Errorf("failed to store selfie: %w", err)
+	}
+
+	return s.write(jobDir, job)
+}
+
+func (s *fileStore) Get(ctx context.Context, id string) (*models.Job, error) {
+	jobDir, err := s.jobDir(id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.read(jobDir)
+}
+
+func (s *fileStore) Update(ctx context.Context, job *models.Job) error {
+	jobDir, err := s.jobDir(job.ID)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := os.Stat(jobDir); err != nil {
+		return ErrJobNotFound
+	}
+	return s.write(jobDir, job)
+}
+
+func (s *fileStore) LoadImages(ctx context.Context, id string) ([]byte, []byte, error) {
+	jobDir, err := s.jobDir(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	idBlob, err := os.ReadFile(filepath.Join(jobDir, idFile))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read ID image: %w", err)
+	}
+	selfieBlob, err := os.ReadFile(filepath.Join(jobDir, selfieFile))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read selfie: %w", err)
+	}
+
+	return idBlob, selfieBlob, nil
+}
+
+func (s *fileStore) DeleteImages(ctx context.Context, id string) error {
+	jobDir, err := s.jobDir(id)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range []string{idFile, selfieFile} {
+		if err := os.Remove(filepath.Join(jobDir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to delete %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (s *fileStore) Unfinished(ctx context.Context) ([]*models.Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+
+	var unfinished []*models.Job
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		job, err := s.read(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		if !job.Done() {
+			unfinished = append(unfinished, job)
+		}
+	}
+	return unfinished, nil
+}
+
+func (s *fileStore) DeleteFinished(ctx context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list jobs: %w", err)
+	}
+
+	var deleted int
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		jobDir := filepath.Join(s.dir, entry.Name())
+		job, err := s.read(jobDir)
+		if err != nil || !job.Done() || !job.UpdatedAt.Before(before) {
+			continue
+		}
+		if err := os.RemoveAll(jobDir); err != nil {
+			return deleted, fmt.Errorf("failed to delete job %s: %w", job.ID, err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// jobDir resolves the directory for a job ID, rejecting IDs that could
+// escape the store directory
+func (s *fileStore) jobDir(id string) (string, error) {
+	if id == "" || id != filepath.Base(id) || id == "." || id == ".." {
+		return "", ErrJobNotFound
+	}
+	return filepath.Join(s.dir, id), nil
+}
+
+func (s *fileStore) read(jobDir string) (*models.Job, error) {
+	data, err := os.ReadFile(filepath.Join(jobDir, jobFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job: %w", err)
+	}
+
+	var job models.Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("failed to decode job: %w", err)
+	}
+	return &job, nil
+}
+
+// write replaces the job record atomically so readers never see a partial file
+func (s *fileStore) write(jobDir string, job *models.Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to encode job: %w", err)
+	}
+
+	tmp := filepath.Join(jobDir, jobFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write job: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(jobDir, jobFile)); err != nil {
+		return fmt.Errorf("failed to write job: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// APIKey describes an issued API key. The token itself is never stored.
+type APIKey struct {
+	// ID is the token's jti claim
+	ID       string   `json:"id"`
+	TenantID string   `json:"tenant_id"`
+	Scopes   []string `json:"scopes"`
+	// SigningKeyID is the kid of the key the token was signed with
+	SigningKeyID string `json:"signing_key_id,omitempty"`
+	CallbackURL  string `json:"callback_url,omitempty"`
+	// Profile is the verification profile the key is bound to, if any
+	Profile   string     `json:"profile,omitempty"`
+	IssuedAt  time.Time  `json:"issued_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+func (k *APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
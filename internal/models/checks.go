@@ -0,0 +1,89 @@
+package models
+
+// ReasonCode is a stable, machine-readable explanation for a failed verification
+type ReasonCode string
+
+const (
+	ReasonInvalidInput          ReasonCode = "invalid_input"
+	ReasonAlreadyVerified       ReasonCode = "already_verified"
+	ReasonInProgress            ReasonCode = "verification_in_progress"
+	ReasonTooManyAttempts       ReasonCode = "too_many_attempts"
+	ReasonDocumentUnreadable    ReasonCode = "document_unreadable"
+	ReasonDocumentExpired       ReasonCode = "document_expired"
+	ReasonDocumentNotYetValid   ReasonCode = "document_not_yet_valid"
+	ReasonMRZSuspicious         ReasonCode = "mrz_suspicious"
+	ReasonUnderage              ReasonCode = "underage"
+	ReasonDateOfBirthUnreadable ReasonCode = "date_of_birth_unreadable"
+	ReasonNameMismatch          ReasonCode = "name_mismatch"
+	ReasonDateOfBirthMismatch   ReasonCode = "date_of_birth_mismatch"
+	ReasonNoFaceDetected        ReasonCode = "no_face_detected"
+	ReasonMultipleFaces         ReasonCode = "multiple_faces"
+	ReasonLowFaceConfidence     ReasonCode = "low_face_confidence"
+	ReasonFaceQualityMissing    ReasonCode = "face_quality_unavailable"
+	ReasonImageTooDark          ReasonCode = "image_too_dark"
+	ReasonImageTooBlurry        ReasonCode = "image_too_blurry"
+	ReasonFaceMismatch          ReasonCode = "face_mismatch"
+)
+
+// CheckName identifies a single step of the verification pipeline
+type CheckName string
+
+const (
+	CheckInputValidation  CheckName = "input_validation"
+	CheckDuplicate        CheckName = "duplicate_check"
+	CheckAttemptLimit     CheckName = "attempt_limit"
+	CheckDocumentAnalysis CheckName = "document_analysis"
+	CheckDocumentValidity CheckName = "document_validity"
+	CheckMRZ              CheckName = "mrz"
+	CheckMinimumAge       CheckName = "minimum_age"
+	CheckNameMatch        CheckName = "name_match"
+	CheckDateOfBirthMatch CheckName = "date_of_birth_match"
+	CheckFaceCount        CheckName = "face_count"
+	CheckFaceConfidence   CheckName = "face_confidence"
+	CheckBrightness       CheckName = "brightness"
+	CheckSharpness        CheckName = "sharpness"
+	CheckSimilarity       CheckName = "similarity"
+)
+
+// CheckOrder lists every check in the order the pipeline runs them
+var CheckOrder = []CheckName{
+	CheckInputValidation,
+	CheckDuplicate,
+	CheckAttemptLimit,
+	CheckDocumentAnalysis,
+	CheckDocumentValidity,
+	CheckMRZ,
+	CheckMinimumAge,
+	CheckNameMatch,
+	CheckDateOfBirthMatch,
+	CheckFaceCount,
+	CheckFaceConfidence,
+	CheckBrightness,
+	CheckSharpness,
+	CheckSimilarity,
+}
+
+// CheckStatus is the outcome of a single check
+type CheckStatus string
+
+const (
+	CheckPassed CheckStatus = "passed"
+	CheckFailed CheckStatus = "failed"
+	// CheckWarning flags a concern that does not fail the verification
+	CheckWarning CheckStatus = "warning"
+	// CheckSkipped is used for checks that were not applicable or were not
+	// reached because an earlier check failed
+	CheckSkipped CheckStatus = "skipped"
+)
+
+// CheckResult describes the outcome of one verification check. Observed and
+// Threshold hold the measured value and the limit it was compared against,
+// when the check has them.
+type CheckResult struct {
+	Name      CheckName   `json:"name"`
+	Status    CheckStatus `json:"status"`
+	Observed  interface{} `json:"observed,omitempty"`
+	Threshold interface{} `json:"threshold,omitempty"`
+	Reason    ReasonCode  `json:"reason,omitempty"`
+	Message   string      `json:"message,omitempty"`
+}
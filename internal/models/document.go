@@ -0,0 +1,65 @@
+package models
+
+// DocumentField is a single value extracted from an identity document
+type DocumentField struct {
+	Value      string  `json:"value"`
+	Normalized string  `json:"normalized,omitempty"`
+	Confidence float32 `json:"confidence"`
+}
+
+// Text returns the raw field value, or an empty string when the field is missing
+func (f *DocumentField) Text() string {
+	if f == nil {
+		return ""
+	}
+	return f.Value
+}
+
+// IdentityDocument holds the normalized fields extracted from an ID document.
+// Fields that could not be read from the document are left nil.
+type IdentityDocument struct {
+	DocumentType   *DocumentField `json:"document_type,omitempty"`
+	FirstName      *DocumentField `json:"first_name,omitempty"`
+	MiddleName     *DocumentField `json:"middle_name,omitempty"`
+	LastName       *DocumentField `json:"last_name,omitempty"`
+	DateOfBirth    *DocumentField `json:"date_of_birth,omitempty"`
+	DocumentNumber *DocumentField `json:"document_number,omitempty"`
+	DateOfIssue    *DocumentField `json:"date_of_issue,omitempty"`
+	ExpirationDate *DocumentField `json:"expiration_date,omitempty"`
+	Address        *DocumentField `json:"address,omitempty"`
+	IssuingState   *DocumentField `json:"issuing_state,omitempty"`
+	MRZCode        *DocumentField `json:"mrz_code,omitempty"`
+}
+
+// MRZResult reports the outcome of validating the document's machine readable
+// zone against its check digits and against the visual inspection zone
+type MRZResult struct {
+	Format         string   `json:"format,omitempty"`
+	Parsed         bool     `json:"parsed"`
+	ChecksumsValid bool     `json:"checksums_valid"`
+	FailedChecks   []string `json:"failed_checks,omitempty"`
+	Mismatches     []string `json:"mismatches,omitempty"`
+}
+
+// Suspicious reports whether the MRZ indicates the document may have been
+// tampered with
+func (r *MRZResult) Suspicious() bool {
+	return r != nil && (!r.Parsed || !r.ChecksumsValid || len(r.Mismatches) > 0)
+}
+
+// FaceDetail describes a face found by a face detector. Quality is nil when
+// the provider did not report image quality.
+type FaceDetail struct {
+	Confidence float32
+	Quality    *FaceQuality
+}
+
+type FaceQuality struct {
+	Brightness float32
+	Sharpness  float32
+}
+
+// FaceMatch is a face in the target image that matched the source face
+type FaceMatch struct {
+	Similarity float32
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// JobStatus is the lifecycle state of an asynchronous verification job
+type JobStatus string
+
+const (
+	JobPending   JobStatus = "pending"
+	JobRunning   JobStatus = "running"
+	JobCompleted JobStatus = "completed"
+	JobFailed    JobStatus = "failed"
+)
+
+// Job is an asynchronous verification request. The uploaded images are
+// stored alongside the job until it has been processed.
+type Job struct {
+	ID        string     `json:"id"`
+	Status    JobStatus  `json:"status"`
+	Request   KYCRequest `json:"request"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	// Result is set once the job completes, and for failures where some
+	// checks were run before the verification was rejected
+	Result    *VerificationResult `json:"result,omitempty"`
+	ErrorKind string              `json:"error_kind,omitempty"`
+	Reason    ReasonCode          `json:"reason,omitempty"`
+	Error     string              `json:"error,omitempty"`
+}
+
+// Done reports whether the job has finished processing
+func (j *Job) Done() bool {
+	return j.Status == JobCompleted || j.Status == JobFailed
+}
+
+type JobResponse struct {
+	Success   bool         `json:"success"`
+	JobID     string       `json:"job_id"`
+	Status    JobStatus    `json:"status"`
+	StatusURL string       `json:"status_url,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+	Result    *KYCResponse `json:"result,omitempty"`
+	Error     string       `json:"error,omitempty"`
+}
@@ -8,13 +8,73 @@ import (
 )
 
 type KYCRequest struct {
-	Email string `form:"email" json:"email" validate:"required,email"`
+	Email       string `form:"email" json:"email" validate:"required,email"`
+	FullName    string `form:"full_name" json:"full_name,omitempty"`
+	DateOfBirth string `form:"date_of_birth" json:"date_of_birth,omitempty"`
+	CallbackURL string `form:"callback_url" json:"callback_url,omitempty"`
+	// Profile names the verification profile to apply. Requests made with
+	// an API key bound to a profile may only name that profile.
+	Profile string `form:"profile" json:"profile,omitempty"`
+	// TenantID is taken from the API key, never from the submitted form
+	TenantID string `form:"-" json:"tenant_id,omitempty"`
+	// SourceIP and UserAgent describe the submitting client for the
+	// verification record
+	SourceIP  string `form:"-" json:"source_ip,omitempty"`
+	UserAgent string `form:"-" json:"user_agent,omitempty"`
+	// ReservationID identifies the verification while it holds the
+	// identity. Jobs use their ID, so a job restarted after a crash takes
+	// over its own reservation; otherwise the verification ID is used.
+	ReservationID string `form:"-" json:"-"`
 }
 
+// EmailRecord is the attempt history of one identity. Email holds the
+// attempt key, see repo.AttemptKey.
 type EmailRecord struct {
-	Email       string    `dynamodbav:"email"`
-	AttemptedAt time.Time `dynamodbav:"attempted_at"`
-	Processed   bool      `dynamodbav:"processed"`
+	Email string `dynamodbav:"email" json:"email"`
+	// AttemptedAt is the time of the latest attempt
+	AttemptedAt time.Time `dynamodbav:"attempted_at" json:"attempted_at"`
+	// Processed is set once an attempt succeeded
+	Processed bool `dynamodbav:"processed" json:"processed"`
+	// Attempts are the most recent attempts, oldest first, up to
+	// MaxAttemptHistory
+	Attempts []Attempt `dynamodbav:"attempts,omitempty" json:"attempts,omitempty"`
+	// PendingID identifies the verification in flight for this identity, if
+	// any. It holds the identity until PendingUntil.
+	PendingID    string    `dynamodbav:"pending_id,omitempty" json:"pending_id,omitempty"`
+	PendingUntil time.Time `dynamodbav:"pending_until,omitempty,unixtime" json:"pending_until,omitzero"`
+}
+
+// MaxAttemptHistory is the number of attempts kept per identity
+const MaxAttemptHistory = 50
+
+// Attempt is one verification attempt of an identity
+type Attempt struct {
+	At      time.Time `dynamodbav:"at" json:"at"`
+	Success bool      `dynamodbav:"success" json:"success"`
+}
+
+// History returns the recorded attempts. Records written before the history
+// was kept count as a single attempt.
+func (e *EmailRecord) History() []Attempt {
+	if len(e.Attempts) == 0 && !e.AttemptedAt.IsZero() {
+		return []Attempt{{At: e.AttemptedAt, Success: e.Processed}}
+	}
+	return e.Attempts
+}
+
+// Record appends an attempt, dropping the oldest beyond MaxAttemptHistory
+func (e *EmailRecord) Record(at time.Time, success bool) {
+	e.Attempts = append(e.History(), Attempt{At: at, Success: success})
+	if len(e.Attempts) > MaxAttemptHistory {
+		e.Attempts = e.Attempts[len(e.Attempts)-MaxAttemptHistory:]
+	}
+	e.AttemptedAt = at
+	e.Processed = e.Processed || success
+}
+
+// InFlight reports whether a verification holds the identity at now
+func (e *EmailRecord) InFlight(now time.Time) bool {
+	return e.PendingID != "" && now.Before(e.PendingUntil)
 }
 
 func (e *EmailRecord) MarshalMap() (map[string]types.AttributeValue, error) {
@@ -22,32 +82,46 @@ func (e *EmailRecord) MarshalMap() (map[string]types.AttributeValue, error) {
 }
 
 type KYCResponse struct {
-	Success    bool    `json:"success"`
-	Verified   bool    `json:"verified"`
-	Similarity float32 `json:"similarity,omitempty"`
-	Message    string  `json:"message"`
-	Error      string  `json:"error,omitempty"`
-}
-
-// FaceValidationCriteria defines the minimum requirements for face validation
-type FaceValidationCriteria struct {
-	MinConfidence float32
-	MinBrightness float32
-	MinSharpness  float32
-	MinSimilarity float32
-}
-
-func DefaultFaceValidationCriteria() FaceValidationCriteria {
-	return FaceValidationCriteria{
-		MinConfidence: 90.0,
-		MinBrightness: 50.0,
-		MinSharpness:  50.0,
-		MinSimilarity: 70.0,
-	}
+	Success        bool              `json:"success"`
+	VerificationID string            `json:"verification_id,omitempty"`
+	Profile        string            `json:"profile,omitempty"`
+	Verified       bool              `json:"verified"`
+	Similarity     float32           `json:"similarity,omitempty"`
+	Reason         ReasonCode        `json:"reason,omitempty"`
+	Message        string            `json:"message"`
+	Document       *IdentityDocument `json:"document,omitempty"`
+	Matches        []FieldMatch      `json:"matches,omitempty"`
+	Suspicious     bool              `json:"suspicious"`
+	MRZ            *MRZResult        `json:"mrz,omitempty"`
+	Checks         []CheckResult     `json:"checks,omitempty"`
+	Error          string            `json:"error,omitempty"`
+}
+
+// FieldMatch reports how an applicant-supplied value compares to the value
+// extracted from the ID document
+type FieldMatch struct {
+	Field     string  `json:"field"`
+	Supplied  string  `json:"supplied"`
+	Extracted string  `json:"extracted"`
+	Score     float64 `json:"score"`
+	Matched   bool    `json:"matched"`
 }
 
 type VerificationResult struct {
-	Verified   bool
-	Similarity float32
-	Message    string
+	// VerificationID identifies the verification record of this result
+	VerificationID string `json:"verification_id,omitempty"`
+	// Profile is the verification profile whose thresholds and checks were
+	// applied
+	Profile    string            `json:"profile,omitempty"`
+	Verified   bool              `json:"verified"`
+	Similarity float32           `json:"similarity"`
+	Reason     ReasonCode        `json:"reason,omitempty"`
+	Message    string            `json:"message"`
+	Document   *IdentityDocument `json:"document,omitempty"`
+	Matches    []FieldMatch      `json:"matches,omitempty"`
+	// Suspicious is set when tamper signals such as MRZ check digit failures
+	// were found. It does not by itself fail the verification.
+	Suspicious bool          `json:"suspicious"`
+	MRZ        *MRZResult    `json:"mrz,omitempty"`
+	Checks     []CheckResult `json:"checks,omitempty"`
 }
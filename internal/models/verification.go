@@ -0,0 +1,75 @@
+package models
+
+import "time"
+
+// VerificationStatus is the outcome of a verification record
+type VerificationStatus string
+
+const (
+	// VerificationVerified means the identity was verified
+	VerificationVerified VerificationStatus = "verified"
+	// VerificationUnverified means the checks ran and the identity was not
+	// verified
+	VerificationUnverified VerificationStatus = "unverified"
+	// VerificationRejected means the submission could not be evaluated, e.g.
+	// invalid input, a duplicate or unusable images
+	VerificationRejected VerificationStatus = "rejected"
+	// VerificationError means a provider or the attempt store failed
+	VerificationError VerificationStatus = "error"
+)
+
+// VerificationRecord is the audit record of a single verification. It is
+// written once the verification has finished, whatever its outcome.
+type VerificationRecord struct {
+	ID       string `json:"id"`
+	TenantID string `json:"tenant_id,omitempty"`
+	Email    string `json:"email"`
+	// Profile is the verification profile that was applied, empty when the
+	// requested profile was unknown
+	Profile     string             `json:"profile,omitempty"`
+	Status      VerificationStatus `json:"status"`
+	Reason      ReasonCode         `json:"reason,omitempty"`
+	Message     string             `json:"message,omitempty"`
+	StartedAt   time.Time          `json:"started_at"`
+	CompletedAt time.Time          `json:"completed_at"`
+	Similarity  float32            `json:"similarity,omitempty"`
+	Suspicious  bool               `json:"suspicious"`
+	Checks      []CheckResult      `json:"checks,omitempty"`
+	Document    *RecordedDocument  `json:"document,omitempty"`
+	SourceIP    string             `json:"source_ip,omitempty"`
+	UserAgent   string             `json:"user_agent,omitempty"`
+}
+
+// RecordedDocument is the part of the extracted document kept in a
+// verification record. Fields that identify the holder are only stored
+// sealed, see package seal.
+type RecordedDocument struct {
+	DocumentType   string `json:"document_type,omitempty"`
+	IssuingState   string `json:"issuing_state,omitempty"`
+	DateOfIssue    string `json:"date_of_issue,omitempty"`
+	ExpirationDate string `json:"expiration_date,omitempty"`
+	// DocumentNumberHash is a keyed hash of the document number, so records
+	// of the same document can be found without storing the number
+	DocumentNumberHash string `json:"document_number_hash,omitempty"`
+	// Sealed holds the encrypted JSON of the holder's names, date of birth,
+	// document number, address and MRZ
+	Sealed string `json:"sealed,omitempty"`
+}
+
+// SealedDocumentFields is the plaintext of RecordedDocument.Sealed
+type SealedDocumentFields struct {
+	FirstName      string `json:"first_name,omitempty"`
+	MiddleName     string `json:"middle_name,omitempty"`
+	LastName       string `json:"last_name,omitempty"`
+	DateOfBirth    string `json:"date_of_birth,omitempty"`
+	DocumentNumber string `json:"document_number,omitempty"`
+	Address        string `json:"address,omitempty"`
+	MRZCode        string `json:"mrz_code,omitempty"`
+}
+
+// VerificationView is a verification record as returned by the API, with
+// the sealed document fields opened into Holder
+type VerificationView struct {
+	VerificationRecord
+	Holder *SealedDocumentFields `json:"holder,omitempty"`
+}
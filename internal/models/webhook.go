@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// Webhook event types
+const (
+	EventVerificationCompleted = "verification.completed"
+	EventVerificationFailed    = "verification.failed"
+)
+
+// WebhookEvent is the JSON body POSTed to a client's callback URL when a
+// verification finishes
+type WebhookEvent struct {
+	ID        string              `json:"id"`
+	Type      string              `json:"type"`
+	CreatedAt time.Time           `json:"created_at"`
+	JobID     string              `json:"job_id,omitempty"`
+	Email     string              `json:"email"`
+	Result    *VerificationResult `json:"result,omitempty"`
+	Reason    ReasonCode          `json:"reason,omitempty"`
+	Error     string              `json:"error,omitempty"`
+}
+
+// WebhookDelivery records a single attempt to deliver a webhook event
+type WebhookDelivery struct {
+	EventID     string        `json:"event_id"`
+	URL         string        `json:"url"`
+	Attempt     int           `json:"attempt"`
+	AttemptedAt time.Time     `json:"attempted_at"`
+	Duration    time.Duration `json:"duration"`
+	StatusCode  int           `json:"status_code,omitempty"`
+	Error       string        `json:"error,omitempty"`
+	Delivered   bool          `json:"delivered"`
+}
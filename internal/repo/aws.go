@@ -0,0 +1,325 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/models"
+	appconfig "github.com/SwanHtetAungPhyo/kyc-api/pkg/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/rekognition"
+	rtype "github.com/aws/aws-sdk-go-v2/service/rekognition/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/aws-sdk-go-v2/service/textract"
+	textraTyp "github.com/aws/aws-sdk-go-v2/service/textract/types"
+)
+
+// defaultRegion is used when neither the configuration nor the shared config
+// profile sets a region
+const defaultRegion = "us-east-1"
+
+type awsRepository struct {
+	textractClient    *textract.Client
+	rekognitionClient *rekognition.Client
+	dynamoDBClient    *dynamodb.Client
+	// attemptsTable is the DynamoDB table attempts are recorded in
+	attemptsTable string
+	// verificationsTable is the DynamoDB table verification records are
+	// kept in
+	verificationsTable string
+}
+
+// NewAWSRepository creates the Textract, Rekognition and DynamoDB clients.
+// Static keys are only used when configured; otherwise credentials come from
+// the SDK's default chain, optionally for a named profile. With an assume
+// role ARN those credentials are exchanged for the role's through STS.
+func NewAWSRepository(cfg appconfig.AWSConfig, attemptsTable, verificationsTable string) (AWSRepository, error) {
+	ctx := context.Background()
+
+	var opts []func(*config.LoadOptions) error
+	if cfg.Region != "" {
+		opts = append(opts, config.WithRegion(cfg.Region))
+	}
+	if cfg.Profile != "" {
+		opts = append(opts, config.WithSharedConfigProfile(cfg.Profile))
+	}
+	if cfg.AccessKeyID != "" {
+		opts = append(opts, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, cfg.SecretAccessKey, cfg.SessionToken)))
+	}
+	if cfg.EndpointURL != "" {
+		opts = append(opts, config.WithBaseEndpoint(cfg.EndpointURL))
+	}
+
+	awsCfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load SDK config: %w", err)
+	}
+	if awsCfg.Region == "" {
+		awsCfg.Region = defaultRegion
+	}
+
+	if cfg.AssumeRoleARN != "" {
+		provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(awsCfg), cfg.AssumeRoleARN,
+			func(o *stscreds.AssumeRoleOptions) {
+				o.RoleSessionName = cfg.AssumeRoleSessionName
+				if cfg.AssumeRoleExternalID != "" {
+					o.ExternalID = aws.String(cfg.AssumeRoleExternalID)
+				}
+			})
+		awsCfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+
+	return &awsRepository{
+		textractClient:     textract.NewFromConfig(awsCfg),
+		rekognitionClient:  rekognition.NewFromConfig(awsCfg),
+		dynamoDBClient:     dynamodb.NewFromConfig(awsCfg),
+		attemptsTable:      attemptsTable,
+		verificationsTable: verificationsTable,
+	}, nil
+}
+
+func (r *awsRepository) AnalyzeID(ctx context.Context, idBlob []byte) (*models.IdentityDocument, error) {
+	input := &textract.AnalyzeIDInput{
+		DocumentPages: []textraTyp.Document{
+			{Bytes: idBlob},
+		},
+	}
+
+	result, err := r.textractClient.AnalyzeID(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("textract analysis failed: %w", classify(err))
+	}
+
+	return parseIdentityDocument(result), nil
+}
+
+func (r *awsRepository) DetectFaces(ctx context.Context, imageBlob []byte) ([]models.FaceDetail, error) {
+	input := &rekognition.DetectFacesInput{
+		Image: &rtype.Image{
+			Bytes: imageBlob,
+		},
+		Attributes: []rtype.Attribute{
+			rtype.AttributeDefault,
+			rtype.AttributeAll,
+		},
+	}
+
+	result, err := r.rekognitionClient.DetectFaces(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("face detection failed: %w", classify(err))
+	}
+
+	faces := make([]models.FaceDetail, 0, len(result.FaceDetails))
+	for _, detail := range result.FaceDetails {
+		face := models.FaceDetail{Confidence: aws.ToFloat32(detail.Confidence)}
+		if q := detail.Quality; q != nil && q.Brightness != nil && q.Sharpness != nil {
+			face.Quality = &models.FaceQuality{
+				Brightness: *q.Brightness,
+				Sharpness:  *q.Sharpness,
+			}
+		}
+		faces = append(faces, face)
+	}
+
+	return faces, nil
+}
+
+func (r *awsRepository) CompareFaces(ctx context.Context, srcBlob, targetBlob []byte, threshold float32) ([]models.FaceMatch, error) {
+	input := &rekognition.CompareFacesInput{
+		SourceImage: &rtype.Image{
+			Bytes: srcBlob,
+		},
+		TargetImage: &rtype.Image{
+			Bytes: targetBlob,
+		},
+		SimilarityThreshold: aws.Float32(threshold),
+	}
+
+	result, err := r.rekognitionClient.CompareFaces(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("face comparison failed: %w", classify(err))
+	}
+
+	matches := make([]models.FaceMatch, 0, len(result.FaceMatches))
+	for _, match := range result.FaceMatches {
+		matches = append(matches, models.FaceMatch{Similarity: aws.ToFloat32(match.Similarity)})
+	}
+
+	return matches, nil
+}
+
+// RecordAttempt appends the attempt with a single UpdateItem, so concurrent
+// attempts for the same key can't overwrite each other. Successful attempts
+// are conditional on the key not being verified yet.
+func (r *awsRepository) RecordAttempt(ctx context.Context, email string, success bool) error {
+	now := time.Now()
+	attemptedAt, err := attributevalue.Marshal(now)
+	if err != nil {
+		return fmt.Errorf("failed to marshal attempt: %w", err)
+	}
+	entry, err := attributevalue.Marshal([]models.Attempt{{At: now, Success: success}})
+	if err != nil {
+		return fmt.Errorf("failed to marshal attempt: %w", err)
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(r.attemptsTable),
+		Key:       emailKey(email),
+		UpdateExpression: aws.String("SET #attempted_at = :now, #processed = if_not_exists(#processed, :false)" +
+			", #attempts = list_append(if_not_exists(#attempts, :empty), :entry)"),
+		ExpressionAttributeNames: map[string]string{
+			"#attempted_at": "attempted_at",
+			"#processed":    "processed",
+			"#attempts":     "attempts",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now":   attemptedAt,
+			":entry": entry,
+			":empty": &types.AttributeValueMemberL{Value: []types.AttributeValue{}},
+			":false": &types.AttributeValueMemberBOOL{Value: false},
+		},
+		ReturnValues: types.ReturnValueUpdatedNew,
+	}
+	if success {
+		input.UpdateExpression = aws.String("SET #attempted_at = :now, #processed = :true" +
+			", #attempts = list_append(if_not_exists(#attempts, :empty), :entry)")
+		input.ConditionExpression = aws.String("attribute_not_exists(#processed) OR #processed = :false")
+		input.ExpressionAttributeValues[":true"] = &types.AttributeValueMemberBOOL{Value: true}
+	}
+
+	result, err := r.dynamoDBClient.UpdateItem(ctx, input)
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return &ConflictError{Key: email}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update the item: %w", classify(err))
+	}
+
+	if attempts, ok := result.Attributes["attempts"].(*types.AttributeValueMemberL); ok && len(attempts.Value) > models.MaxAttemptHistory {
+		r.trimAttempts(ctx, email, len(attempts.Value))
+	}
+	return nil
+}
+
+// trimAttempts drops the oldest attempts beyond models.MaxAttemptHistory. The
+// update only applies while the history still has length entries; when a
+// concurrent attempt got there first, the next write trims instead. The
+// attempt itself is already recorded, so failures are ignored.
+func (r *awsRepository) trimAttempts(ctx context.Context, email string, length int) {
+	remove := make([]string, 0, length-models.MaxAttemptHistory)
+	for i := 0; i < length-models.MaxAttemptHistory; i++ {
+		remove = append(remove, fmt.Sprintf("#attempts[%d]", i))
+	}
+
+	_, _ = r.dynamoDBClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                aws.String(r.attemptsTable),
+		Key:                      emailKey(email),
+		UpdateExpression:         aws.String("REMOVE " + strings.Join(remove, ", ")),
+		ConditionExpression:      aws.String("size(#attempts) = :length"),
+		ExpressionAttributeNames: map[string]string{"#attempts": "attempts"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":length": &types.AttributeValueMemberN{Value: strconv.Itoa(length)},
+		},
+	})
+}
+
+func (r *awsRepository) GetAttempts(ctx context.Context, email string) (*models.EmailRecord, error) {
+	result, err := r.dynamoDBClient.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.attemptsTable),
+		Key:       emailKey(email),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get item: %w", classify(err))
+	}
+
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var record models.EmailRecord
+	if err := attributevalue.UnmarshalMap(result.Item, &record); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal data: %s", err.Error())
+	}
+	return &record, nil
+}
+
+// Reserve sets the key's lease with a conditional UpdateItem, which fails
+// while the key is verified or another reservation's lease has not expired
+func (r *awsRepository) Reserve(ctx context.Context, email, reservation string, lease time.Duration) error {
+	now := time.Now()
+
+	_, err := r.dynamoDBClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(r.attemptsTable),
+		Key:              emailKey(email),
+		UpdateExpression: aws.String("SET #pending_id = :id, #pending_until = :until"),
+		ConditionExpression: aws.String("(attribute_not_exists(#processed) OR #processed = :false)" +
+			" AND (attribute_not_exists(#pending_until) OR #pending_until <= :now OR #pending_id = :id)"),
+		ExpressionAttributeNames: map[string]string{
+			"#processed":     "processed",
+			"#pending_id":    "pending_id",
+			"#pending_until": "pending_until",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":id":    &types.AttributeValueMemberS{Value: reservation},
+			":until": unixTime(now.Add(lease)),
+			":now":   unixTime(now),
+			":false": &types.AttributeValueMemberBOOL{Value: false},
+		},
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		processed, _ := conditionFailed.Item["processed"].(*types.AttributeValueMemberBOOL)
+		return &ConflictError{Key: email, InProgress: processed == nil || !processed.Value}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to reserve the item: %w", classify(err))
+	}
+	return nil
+}
+
+// Release removes the lease unless another reservation has taken it over
+func (r *awsRepository) Release(ctx context.Context, email, reservation string) error {
+	_, err := r.dynamoDBClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(r.attemptsTable),
+		Key:                 emailKey(email),
+		UpdateExpression:    aws.String("REMOVE #pending_id, #pending_until"),
+		ConditionExpression: aws.String("#pending_id = :id"),
+		ExpressionAttributeNames: map[string]string{
+			"#pending_id":    "pending_id",
+			"#pending_until": "pending_until",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":id": &types.AttributeValueMemberS{Value: reservation},
+		},
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if err != nil && !errors.As(err, &conditionFailed) {
+		return fmt.Errorf("failed to release the item: %w", classify(err))
+	}
+	return nil
+}
+
+// unixTime encodes t like the unixtime attributes of models.EmailRecord, so
+// expressions can compare them
+func unixTime(t time.Time) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: strconv.FormatInt(t.Unix(), 10)}
+}
+
+func emailKey(email string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"email": &types.AttributeValueMemberS{Value: email},
+	}
+}
@@ -0,0 +1,161 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/models"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Global secondary indexes of the verifications table. Both are sorted by
+// the created attribute, see createdKey.
+const (
+	// verificationTenantIndex is partitioned by tenant_key
+	verificationTenantIndex = "tenant_key-created-index"
+	// verificationEmailIndex is partitioned by email_key
+	verificationEmailIndex = "email_key-created-index"
+)
+
+// Verification records are stored under their json attribute names, keyed
+// by id, with the index attributes added
+func marshalVerification(record *models.VerificationRecord) (map[string]types.AttributeValue, error) {
+	item, err := attributevalue.MarshalMapWithOptions(record, func(o *attributevalue.EncoderOptions) {
+		o.TagKey = "json"
+	})
+	if err != nil {
+		return nil, err
+	}
+	item["tenant_key"] = &types.AttributeValueMemberS{Value: tenantPartition(record.TenantID)}
+	item["email_key"] = &types.AttributeValueMemberS{Value: emailPartition(record.TenantID, record.Email)}
+	item["created"] = &types.AttributeValueMemberS{Value: createdKey(record.StartedAt)}
+	return item, nil
+}
+
+func unmarshalVerification(item map[string]types.AttributeValue) (*models.VerificationRecord, error) {
+	var record models.VerificationRecord
+	err := attributevalue.UnmarshalMapWithOptions(item, &record, func(o *attributevalue.DecoderOptions) {
+		o.TagKey = "json"
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal verification: %w", err)
+	}
+	return &record, nil
+}
+
+// SaveVerification puts the record unless one with its ID already exists
+func (r *awsRepository) SaveVerification(ctx context.Context, record *models.VerificationRecord) error {
+	item, err := marshalVerification(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal verification: %w", err)
+	}
+
+	_, err = r.dynamoDBClient.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:                aws.String(r.verificationsTable),
+		Item:                     item,
+		ConditionExpression:      aws.String("attribute_not_exists(#id)"),
+		ExpressionAttributeNames: map[string]string{"#id": "id"},
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return fmt.Errorf("verification %q already exists", record.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to put the verification: %w", classify(err))
+	}
+	return nil
+}
+
+func (r *awsRepository) GetVerification(ctx context.Context, tenantID, id string) (*models.VerificationRecord, error) {
+	result, err := r.dynamoDBClient.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.verificationsTable),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the verification: %w", classify(err))
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	record, err := unmarshalVerification(result.Item)
+	if err != nil {
+		return nil, err
+	}
+	if record.TenantID != tenantID {
+		return nil, nil
+	}
+	return record, nil
+}
+
+// ListVerifications queries the tenant or email index newest first. The
+// status filter is applied after DynamoDB's limit, so queries continue
+// until one record more than the page holds is found, which tells whether
+// another page follows, or the partition is exhausted.
+func (r *awsRepository) ListVerifications(ctx context.Context, query VerificationQuery) (*VerificationPage, error) {
+	position, err := decodeCursor(query.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	index, partitionKey := verificationTenantIndex, "tenant_key"
+	if query.Email != "" {
+		index, partitionKey = verificationEmailIndex, "email_key"
+	}
+	from, to := createdRange(query)
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.verificationsTable),
+		IndexName:              aws.String(index),
+		KeyConditionExpression: aws.String("#partition = :partition AND #created BETWEEN :from AND :to"),
+		ExpressionAttributeNames: map[string]string{
+			"#partition": partitionKey,
+			"#created":   "created",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":partition": &types.AttributeValueMemberS{Value: query.partition()},
+			":from":      &types.AttributeValueMemberS{Value: from},
+			":to":        &types.AttributeValueMemberS{Value: to},
+		},
+		ScanIndexForward: aws.Bool(false),
+	}
+	if query.Status != "" {
+		input.FilterExpression = aws.String("#status = :status")
+		input.ExpressionAttributeNames["#status"] = "status"
+		input.ExpressionAttributeValues[":status"] = &types.AttributeValueMemberS{Value: string(query.Status)}
+	}
+	if position != nil {
+		input.ExclusiveStartKey = map[string]types.AttributeValue{
+			"id":         &types.AttributeValueMemberS{Value: position.ID},
+			partitionKey: &types.AttributeValueMemberS{Value: query.partition()},
+			"created":    &types.AttributeValueMemberS{Value: position.Created},
+		}
+	}
+
+	var records []models.VerificationRecord
+	for {
+		input.Limit = aws.Int32(int32(query.Limit + 1 - len(records)))
+		result, err := r.dynamoDBClient.Query(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query verifications: %w", classify(err))
+		}
+		for _, item := range result.Items {
+			record, err := unmarshalVerification(item)
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, *record)
+		}
+
+		if result.LastEvaluatedKey == nil || len(records) > query.Limit {
+			return newPage(records, query.Limit), nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
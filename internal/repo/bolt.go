@@ -0,0 +1,131 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/models"
+	"github.com/SwanHtetAungPhyo/kyc-api/pkg/boltdb"
+	bolt "go.etcd.io/bbolt"
+)
+
+var attemptsBucket = []byte("attempts")
+
+// BoltAttemptStore is an AttemptStore backed by an embedded bbolt database,
+// for single-node deployments and tests without DynamoDB. Records are the
+// JSON encoded models.EmailRecord keyed by attempt key.
+type BoltAttemptStore struct {
+	db *bolt.DB
+}
+
+func NewBoltAttemptStore(path string) (*BoltAttemptStore, error) {
+	db, err := boltdb.Open(path, "attempt store", attemptsBucket)
+	if err != nil {
+		return nil, err
+	}
+	return &BoltAttemptStore{db: db}, nil
+}
+
+// RecordAttempt appends the attempt to the key's history within a single
+// read-write transaction. Successful attempts for a verified key are
+// rejected with a *ConflictError.
+func (s *BoltAttemptStore) RecordAttempt(ctx context.Context, email string, success bool) error {
+	err := s.update(email, func(record *models.EmailRecord) error {
+		if success && record.Processed {
+			return &ConflictError{Key: email}
+		}
+		record.Record(time.Now(), success)
+		return nil
+	})
+	var conflict *ConflictError
+	if errors.As(err, &conflict) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to put the item: %w", err)
+	}
+	return nil
+}
+
+func (s *BoltAttemptStore) GetAttempts(ctx context.Context, email string) (*models.EmailRecord, error) {
+	var record *models.EmailRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(attemptsBucket).Get([]byte(email))
+		if data == nil {
+			return nil
+		}
+		record = &models.EmailRecord{}
+		return json.Unmarshal(data, record)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get item: %w", err)
+	}
+
+	return record, nil
+}
+
+// Reserve sets the key's lease within a single read-write transaction
+func (s *BoltAttemptStore) Reserve(ctx context.Context, email, reservation string, lease time.Duration) error {
+	err := s.update(email, func(record *models.EmailRecord) error {
+		now := time.Now()
+		if record.Processed || record.InFlight(now) && record.PendingID != reservation {
+			return &ConflictError{Key: email, InProgress: !record.Processed}
+		}
+		record.PendingID = reservation
+		record.PendingUntil = now.Add(lease)
+		return nil
+	})
+	var conflict *ConflictError
+	if errors.As(err, &conflict) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to reserve the item: %w", err)
+	}
+	return nil
+}
+
+func (s *BoltAttemptStore) Release(ctx context.Context, email, reservation string) error {
+	err := s.update(email, func(record *models.EmailRecord) error {
+		if record.PendingID == reservation {
+			record.PendingID = ""
+			record.PendingUntil = time.Time{}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to release the item: %w", err)
+	}
+	return nil
+}
+
+// update applies fn to the key's record and stores the result in a single
+// read-write transaction. Nothing is written when fn fails.
+func (s *BoltAttemptStore) update(email string, fn func(record *models.EmailRecord) error) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(attemptsBucket)
+
+		record := models.EmailRecord{Email: email}
+		if data := bucket.Get([]byte(email)); data != nil {
+			if err := json.Unmarshal(data, &record); err != nil {
+				return err
+			}
+		}
+		if err := fn(&record); err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(email), data)
+	})
+}
+
+func (s *BoltAttemptStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,130 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/models"
+)
+
+func newBoltAttemptStore(t *testing.T) (*BoltAttemptStore, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "attempts.db")
+	store, err := NewBoltAttemptStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store, path
+}
+
+func TestBoltRecordAttemptAfterSuccess(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newBoltAttemptStore(t)
+
+	if err := store.RecordAttempt(ctx, "jane@example.com", true); err != nil {
+		t.Fatalf("RecordAttempt() error = %v", err)
+	}
+
+	err := store.RecordAttempt(ctx, "jane@example.com", true)
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || conflict.InProgress {
+		t.Errorf("RecordAttempt() error = %v, want a conflict with the verified identity", err)
+	}
+	if err := store.Reserve(ctx, "jane@example.com", "r1", time.Minute); !errors.As(err, &conflict) || conflict.InProgress {
+		t.Errorf("Reserve() error = %v, want a conflict with the verified identity", err)
+	}
+
+	record, err := store.GetAttempts(ctx, "jane@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record == nil || !record.Processed || len(record.History()) != 1 {
+		t.Errorf("GetAttempts() = %+v, want the one successful attempt", record)
+	}
+}
+
+func TestBoltRecordAttemptTrimsHistory(t *testing.T) {
+	ctx := context.Background()
+	store, path := newBoltAttemptStore(t)
+
+	for range models.MaxAttemptHistory + 5 {
+		if err := store.RecordAttempt(ctx, "jane@example.com", false); err != nil {
+			t.Fatalf("RecordAttempt() error = %v", err)
+		}
+	}
+	if err := store.RecordAttempt(ctx, "jane@example.com", true); err != nil {
+		t.Fatalf("RecordAttempt() error = %v", err)
+	}
+
+	// The trimmed history is what was stored, not just what was returned
+	store.Close()
+	store, err := NewBoltAttemptStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	record, err := store.GetAttempts(ctx, "jane@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	history := record.History()
+	if len(history) != models.MaxAttemptHistory {
+		t.Fatalf("History() = %d attempts, want %d", len(history), models.MaxAttemptHistory)
+	}
+	if last := history[len(history)-1]; !last.Success {
+		t.Errorf("last attempt = %+v, want the newest attempt kept", last)
+	}
+}
+
+func TestBoltReserveRelease(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newBoltAttemptStore(t)
+	const key = "jane@example.com"
+	lease := 50 * time.Millisecond
+
+	if err := store.Reserve(ctx, key, "r1", lease); err != nil {
+		t.Fatalf("Reserve(r1) error = %v", err)
+	}
+	var conflict *ConflictError
+	if err := store.Reserve(ctx, key, "r2", lease); !errors.As(err, &conflict) || !conflict.InProgress {
+		t.Errorf("Reserve(r2) error = %v, want a conflict with the verification in progress", err)
+	}
+	if err := store.Reserve(ctx, key, "r1", lease); err != nil {
+		t.Errorf("Reserve(r1) again error = %v, want the reservation taken over", err)
+	}
+
+	// Once the lease expires another verification may take the key
+	time.Sleep(2 * lease)
+	if err := store.Reserve(ctx, key, "r2", time.Minute); err != nil {
+		t.Fatalf("Reserve(r2) after the lease error = %v", err)
+	}
+
+	// Releasing an expired reservation leaves the new one in place
+	if err := store.Release(ctx, key, "r1"); err != nil {
+		t.Fatalf("Release(r1) error = %v", err)
+	}
+	if err := store.Reserve(ctx, key, "r3", time.Minute); !errors.As(err, &conflict) {
+		t.Errorf("Reserve(r3) error = %v, want r2 to still hold the key", err)
+	}
+
+	if err := store.Release(ctx, key, "r2"); err != nil {
+		t.Fatalf("Release(r2) error = %v", err)
+	}
+	if err := store.Reserve(ctx, key, "r3", time.Minute); err != nil {
+		t.Errorf("Reserve(r3) after release error = %v", err)
+	}
+
+	record, err := store.GetAttempts(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record == nil || len(record.History()) != 0 {
+		t.Errorf("GetAttempts() = %+v, want reservations to record no attempts", record)
+	}
+}
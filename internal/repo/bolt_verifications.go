@@ -0,0 +1,179 @@
+package repo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/models"
+	"github.com/SwanHtetAungPhyo/kyc-api/pkg/boltdb"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	verificationsBucket = []byte("verifications")
+	// verificationIndexBucket lists every record under its tenant and email
+	// partitions, see indexKeys
+	verificationIndexBucket = []byte("verifications_index")
+)
+
+// BoltVerificationStore is a VerificationStore backed by an embedded bbolt
+// database. Records are JSON encoded and keyed by ID.
+type BoltVerificationStore struct {
+	db *bolt.DB
+}
+
+func NewBoltVerificationStore(path string) (*BoltVerificationStore, error) {
+	db, err := boltdb.Open(path, "verification store", verificationsBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	// Databases written before records were indexed are indexed once
+	err = db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(verificationIndexBucket) != nil {
+			return nil
+		}
+		index, err := tx.CreateBucket(verificationIndexBucket)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(verificationsBucket).ForEach(func(_, data []byte) error {
+			var record models.VerificationRecord
+			if err := json.Unmarshal(data, &record); err != nil {
+				return err
+			}
+			return putIndexKeys(index, &record)
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to index verification store: %w", err)
+	}
+
+	return &BoltVerificationStore{db: db}, nil
+}
+
+// indexKeys are the keys of the record in verificationIndexBucket: its
+// partition, then its start time and ID, so that within a partition keys
+// sort by time
+func indexKeys(record *models.VerificationRecord) [][]byte {
+	suffix := "\x00" + createdKey(record.StartedAt) + "\x00" + record.ID
+	return [][]byte{
+		[]byte(tenantPartition(record.TenantID) + suffix),
+		[]byte(emailPartition(record.TenantID, record.Email) + suffix),
+	}
+}
+
+func putIndexKeys(index *bolt.Bucket, record *models.VerificationRecord) error {
+	for _, key := range indexKeys(record) {
+		if err := index.Put(key, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *BoltVerificationStore) SaveVerification(ctx context.Context, record *models.VerificationRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal verification: %w", err)
+	}
+
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(verificationsBucket)
+		if bucket.Get([]byte(record.ID)) != nil {
+			return fmt.Errorf("verification %q already exists", record.ID)
+		}
+		if err := bucket.Put([]byte(record.ID), data); err != nil {
+			return err
+		}
+		return putIndexKeys(tx.Bucket(verificationIndexBucket), record)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put the verification: %w", err)
+	}
+	return nil
+}
+
+func (s *BoltVerificationStore) GetVerification(ctx context.Context, tenantID, id string) (*models.VerificationRecord, error) {
+	var record *models.VerificationRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(verificationsBucket).Get([]byte(id))
+		if data == nil {
+			return nil
+		}
+		record = &models.VerificationRecord{}
+		return json.Unmarshal(data, record)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the verification: %w", err)
+	}
+
+	if record == nil || record.TenantID != tenantID {
+		return nil, nil
+	}
+	return record, nil
+}
+
+// ListVerifications walks the query's index partition backwards from the
+// cursor, or from the end of the time range
+func (s *BoltVerificationStore) ListVerifications(ctx context.Context, query VerificationQuery) (*VerificationPage, error) {
+	position, err := decodeCursor(query.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	from, to := createdRange(query)
+	prefix := query.partition() + "\x00"
+	lower := []byte(prefix + from)
+	// upper sorts after every key the page may hold
+	upper := []byte(prefix + to + "\x01")
+	if position != nil {
+		upper = []byte(prefix + position.Created + "\x00" + position.ID)
+	}
+
+	var records []models.VerificationRecord
+	err = s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(verificationsBucket)
+		cursor := tx.Bucket(verificationIndexBucket).Cursor()
+
+		key, _ := cursor.Seek(upper)
+		if key == nil {
+			key, _ = cursor.Last()
+		} else {
+			key, _ = cursor.Prev()
+		}
+
+		for ; key != nil && bytes.Compare(key, lower) >= 0; key, _ = cursor.Prev() {
+			id := key[bytes.LastIndexByte(key, 0)+1:]
+			data := bucket.Get(id)
+			if data == nil {
+				continue
+			}
+
+			var record models.VerificationRecord
+			if err := json.Unmarshal(data, &record); err != nil {
+				return err
+			}
+			if !query.matches(&record) {
+				continue
+			}
+			records = append(records, record)
+			if len(records) > query.Limit {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list verifications: %w", err)
+	}
+
+	return newPage(records, query.Limit), nil
+}
+
+func (s *BoltVerificationStore) Close() error {
+	return s.db.Close()
+}
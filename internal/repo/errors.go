@@ -0,0 +1,98 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/aws/smithy-go"
+)
+
+// Provider-neutral error classes. Repository methods wrap the underlying
+// error with one of these so callers can react with errors.Is without
+// depending on AWS error types.
+var (
+	ErrThrottled    = errors.New("provider throttled the request")
+	ErrUnavailable  = errors.New("provider unavailable")
+	ErrInvalidInput = errors.New("provider rejected the input")
+)
+
+// ConflictError is returned by RecordAttempt when a successful attempt is
+// recorded for an identity that an earlier attempt already verified, and by
+// Reserve when the identity is verified or another verification holds it.
+// Key holds the email, so it is left out of the message, which gets logged.
+type ConflictError struct {
+	Key string
+	// InProgress is set when another verification holds the identity
+	InProgress bool
+}
+
+func (e *ConflictError) Error() string {
+	if e.InProgress {
+		return "attempt key is being verified by another request"
+	}
+	return "attempt key is already verified"
+}
+
+var throttlingCodes = map[string]bool{
+	"ThrottlingException":                    true,
+	"ThrottledException":                     true,
+	"ProvisionedThroughputExceededException": true,
+	"RequestLimitExceeded":                   true,
+	"LimitExceededException":                 true,
+	"TooManyRequestsException":               true,
+}
+
+var invalidInputCodes = map[string]bool{
+	"InvalidParameterException":    true,
+	"InvalidImageFormatException":  true,
+	"ImageTooLargeException":       true,
+	"UnsupportedDocumentException": true,
+	"BadDocumentException":         true,
+	"DocumentTooLargeException":    true,
+}
+
+var unavailableCodes = map[string]bool{
+	"InternalServerError":          true,
+	"InternalServerErrorException": true,
+	"ServiceUnavailable":           true,
+	"ServiceUnavailableException":  true,
+}
+
+// classify wraps an AWS SDK error with the matching provider-neutral error.
+// Errors that fit no class are returned unchanged.
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch code := apiErr.ErrorCode(); {
+		case throttlingCodes[code]:
+			return fmt.Errorf("%w: %w", ErrThrottled, err)
+		case invalidInputCodes[code]:
+			return fmt.Errorf("%w: %w", ErrInvalidInput, err)
+		case unavailableCodes[code]:
+			return fmt.Errorf("%w: %w", ErrUnavailable, err)
+		}
+	}
+
+	var statusErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &statusErr) {
+		switch code := statusErr.HTTPStatusCode(); {
+		case code == 429:
+			return fmt.Errorf("%w: %w", ErrThrottled, err)
+		case code >= 500:
+			return fmt.Errorf("%w: %w", ErrUnavailable, err)
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	return err
+}
@@ -0,0 +1,408 @@
+package repo
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/models"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/textract"
+	textraTyp "github.com/aws/aws-sdk-go-v2/service/textract/types"
+)
+
+// FakeScenario scripts how the fake repository responds to an image. It is
+// read, in order of precedence, from:
+//
+//   - the image itself, when the upload is a JSON document
+//   - <fixtures dir>/<sha256 of image>.json
+//   - the image's embedded comment (JPEG COM segment, or a PNG tEXt chunk
+//     with the keyword "Comment" or "kyc-fake")
+//
+// Images without a scenario use DefaultFakeScenario.
+type FakeScenario struct {
+	// Fields are Textract AnalyzeID field types (FIRST_NAME, DATE_OF_BIRTH,
+	// MRZ_CODE, ...) mapped to their text
+	Fields     map[string]string `json:"fields,omitempty"`
+	NoDocument bool              `json:"no_document,omitempty"`
+
+	Faces      *int     `json:"faces,omitempty"`
+	Confidence *float32 `json:"confidence,omitempty"`
+	Brightness *float32 `json:"brightness,omitempty"`
+	Sharpness  *float32 `json:"sharpness,omitempty"`
+	Similarity *float32 `json:"similarity,omitempty"`
+
+	// Errors injects a failure per operation ("analyze_id", "detect_faces",
+	// "compare_faces"). The values "throttled", "unavailable" and
+	// "invalid_input" produce the matching repository error; any other value
+	// is returned as a plain error.
+	Errors map[string]string `json:"errors,omitempty"`
+	// Delay is added before every operation, e.g. "3s"
+	Delay string `json:"delay,omitempty"`
+}
+
+// DefaultFakeScenario describes a valid passport and a matching selfie
+func DefaultFakeScenario() FakeScenario {
+	return FakeScenario{
+		Fields: map[string]string{
+			"ID_TYPE":         "PASSPORT",
+			"FIRST_NAME":      "JANE",
+			"LAST_NAME":       "DOE",
+			"DATE_OF_BIRTH":   "1990-01-01",
+			"DOCUMENT_NUMBER": "X1234567",
+			"DATE_OF_ISSUE":   time.Now().AddDate(-1, 0, 0).Format(time.DateOnly),
+			"EXPIRATION_DATE": time.Now().AddDate(5, 0, 0).Format(time.DateOnly),
+		},
+		Faces:      aws.Int(1),
+		Confidence: aws.Float32(99.9),
+		Brightness: aws.Float32(80),
+		Sharpness:  aws.Float32(80),
+		Similarity: aws.Float32(98),
+	}
+}
+
+const (
+	fakeOpAnalyzeID    = "analyze_id"
+	fakeOpDetectFaces  = "detect_faces"
+	fakeOpCompareFaces = "compare_faces"
+)
+
+// fakeRepository is a deterministic in-process AWSRepository for local
+// development and CI. Attempts and verification records are kept in memory.
+type fakeRepository struct {
+	fixturesDir string
+
+	mu            sync.RWMutex
+	attempts      map[string]models.EmailRecord
+	verifications map[string]models.VerificationRecord
+}
+
+func NewFakeRepository(fixturesDir string) (AWSRepository, error) {
+	if fixturesDir != "" {
+		if info, err := os.Stat(fixturesDir); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("fixtures directory %q is not readable", fixturesDir)
+		}
+	}
+
+	return &fakeRepository{
+		fixturesDir:   fixturesDir,
+		attempts:      make(map[string]models.EmailRecord),
+		verifications: make(map[string]models.VerificationRecord),
+	}, nil
+}
+
+func (r *fakeRepository) AnalyzeID(ctx context.Context, idBlob []byte) (*models.IdentityDocument, error) {
+	scenario, err := r.scenario(ctx, idBlob, fakeOpAnalyzeID)
+	if err != nil {
+		return nil, fmt.Errorf("textract analysis failed: %w", err)
+	}
+	if scenario.NoDocument {
+		return nil, nil
+	}
+
+	output := &textract.AnalyzeIDOutput{
+		IdentityDocuments: []textraTyp.IdentityDocument{{}},
+	}
+	for fieldType, value := range scenario.Fields {
+		output.IdentityDocuments[0].IdentityDocumentFields = append(output.IdentityDocuments[0].IdentityDocumentFields,
+			textraTyp.IdentityDocumentField{
+				Type:           &textraTyp.AnalyzeIDDetections{Text: aws.String(fieldType)},
+				ValueDetection: &textraTyp.AnalyzeIDDetections{Text: aws.String(value), Confidence: aws.Float32(99)},
+			})
+	}
+
+	return parseIdentityDocument(output), nil
+}
+
+func (r *fakeRepository) DetectFaces(ctx context.Context, imageBlob []byte) ([]models.FaceDetail, error) {
+	scenario, err := r.scenario(ctx, imageBlob, fakeOpDetectFaces)
+	if err != nil {
+		return nil, fmt.Errorf("face detection failed: %w", err)
+	}
+
+	// A negative count in a scenario means no faces rather than a panic
+	faces := make([]models.FaceDetail, max(aws.ToInt(scenario.Faces), 0))
+	for i := range faces {
+		faces[i] = models.FaceDetail{
+			Confidence: aws.ToFloat32(scenario.Confidence),
+			Quality: &models.FaceQuality{
+				Brightness: aws.ToFloat32(scenario.Brightness),
+				Sharpness:  aws.ToFloat32(scenario.Sharpness),
+			},
+		}
+	}
+
+	return faces, nil
+}
+
+// CompareFaces reports the similarity scripted on the target (selfie) image,
+// falling back to the source image's scenario
+func (r *fakeRepository) CompareFaces(ctx context.Context, srcBlob, targetBlob []byte, threshold float32) ([]models.FaceMatch, error) {
+	scenario, err := r.scenario(ctx, targetBlob, fakeOpCompareFaces)
+	if err != nil {
+		return nil, fmt.Errorf("face comparison failed: %w", err)
+	}
+
+	if source, ok := r.lookup(srcBlob); ok && source.Similarity != nil && !r.scripted(targetBlob) {
+		scenario.Similarity = source.Similarity
+	}
+
+	similarity := aws.ToFloat32(scenario.Similarity)
+	if similarity < threshold {
+		return nil, nil
+	}
+	return []models.FaceMatch{{Similarity: similarity}}, nil
+}
+
+func (r *fakeRepository) RecordAttempt(ctx context.Context, email string, success bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record := r.attempts[email]
+	if success && record.Processed {
+		return &ConflictError{Key: email}
+	}
+	record.Email = email
+	record.Record(time.Now(), success)
+	r.attempts[email] = record
+	return nil
+}
+
+func (r *fakeRepository) GetAttempts(ctx context.Context, email string) (*models.EmailRecord, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	record, ok := r.attempts[email]
+	if !ok {
+		return nil, nil
+	}
+	record.Attempts = slices.Clone(record.Attempts)
+	return &record, nil
+}
+
+func (r *fakeRepository) Reserve(ctx context.Context, email, reservation string, lease time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	record := r.attempts[email]
+	if record.Processed || record.InFlight(now) && record.PendingID != reservation {
+		return &ConflictError{Key: email, InProgress: !record.Processed}
+	}
+	record.Email = email
+	record.PendingID = reservation
+	record.PendingUntil = now.Add(lease)
+	r.attempts[email] = record
+	return nil
+}
+
+func (r *fakeRepository) Release(ctx context.Context, email, reservation string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record, ok := r.attempts[email]
+	if ok && record.PendingID == reservation {
+		record.PendingID = ""
+		record.PendingUntil = time.Time{}
+		r.attempts[email] = record
+	}
+	return nil
+}
+
+func (r *fakeRepository) SaveVerification(ctx context.Context, record *models.VerificationRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.verifications[record.ID]; ok {
+		return fmt.Errorf("verification %q already exists", record.ID)
+	}
+	r.verifications[record.ID] = *record
+	return nil
+}
+
+func (r *fakeRepository) GetVerification(ctx context.Context, tenantID, id string) (*models.VerificationRecord, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	record, ok := r.verifications[id]
+	if !ok || record.TenantID != tenantID {
+		return nil, nil
+	}
+	return &record, nil
+}
+
+func (r *fakeRepository) ListVerifications(ctx context.Context, query VerificationQuery) (*VerificationPage, error) {
+	position, err := decodeCursor(query.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	from, to := createdRange(query)
+
+	r.mu.RLock()
+	var records []models.VerificationRecord
+	for _, record := range r.verifications {
+		if record.TenantID != query.TenantID || query.Email != "" && record.Email != query.Email || !query.matches(&record) {
+			continue
+		}
+		created := createdKey(record.StartedAt)
+		if created < from || created > to {
+			continue
+		}
+		// Only records after the cursor, i.e. older ones, are left to list
+		if position != nil && (created > position.Created || created == position.Created && record.ID >= position.ID) {
+			continue
+		}
+		records = append(records, record)
+	}
+	r.mu.RUnlock()
+
+	slices.SortFunc(records, func(a, b models.VerificationRecord) int {
+		if c := cmp.Compare(createdKey(b.StartedAt), createdKey(a.StartedAt)); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.ID, a.ID)
+	})
+	return newPage(records, query.Limit), nil
+}
+
+// scenario resolves the scenario for an image, applies its delay and
+// returns its injected error for op, if any
+func (r *fakeRepository) scenario(ctx context.Context, blob []byte, op string) (FakeScenario, error) {
+	scenario, ok := r.lookup(blob)
+	if !ok {
+		return DefaultFakeScenario(), nil
+	}
+
+	if scenario.Delay != "" {
+		delay, err := time.ParseDuration(scenario.Delay)
+		if err != nil {
+			return scenario, fmt.Errorf("invalid fake scenario delay: %w", err)
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return scenario, fmt.Errorf("%w: %w", ErrUnavailable, ctx.Err())
+		}
+	}
+
+	switch injected := scenario.Errors[op]; injected {
+	case "":
+		return scenario, nil
+	case "throttled":
+		return scenario, fmt.Errorf("%w: injected by fake provider", ErrThrottled)
+	case "unavailable":
+		return scenario, fmt.Errorf("%w: injected by fake provider", ErrUnavailable)
+	case "invalid_input":
+		return scenario, fmt.Errorf("%w: injected by fake provider", ErrInvalidInput)
+	default:
+		return scenario, errors.New(injected)
+	}
+}
+
+func (r *fakeRepository) scripted(blob []byte) bool {
+	_, ok := r.lookup(blob)
+	return ok
+}
+
+// lookup finds the scenario scripted for an image, with unset values filled
+// in from DefaultFakeScenario
+func (r *fakeRepository) lookup(blob []byte) (FakeScenario, bool) {
+	raw, ok := r.rawScenario(blob)
+	if !ok {
+		return FakeScenario{}, false
+	}
+
+	scenario := DefaultFakeScenario()
+	if err := json.Unmarshal(raw, &scenario); err != nil {
+		return FakeScenario{}, false
+	}
+	return scenario, true
+}
+
+func (r *fakeRepository) rawScenario(blob []byte) ([]byte, bool) {
+	if trimmed := bytes.TrimSpace(blob); len(trimmed) > 0 && trimmed[0] == '{' && json.Valid(trimmed) {
+		return trimmed, true
+	}
+
+	if r.fixturesDir != "" {
+		sum := sha256.Sum256(blob)
+		data, err := os.ReadFile(filepath.Join(r.fixturesDir, hex.EncodeToString(sum[:])+".json"))
+		if err == nil {
+			return data, true
+		}
+	}
+
+	if comment, ok := imageComment(blob); ok && json.Valid(comment) {
+		return comment, true
+	}
+
+	return nil, false
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// imageComment returns the first comment embedded in a JPEG or PNG image
+func imageComment(blob []byte) ([]byte, bool) {
+	switch {
+	case bytes.HasPrefix(blob, []byte{0xFF, 0xD8}):
+		return jpegComment(blob)
+	case bytes.HasPrefix(blob, pngSignature):
+		return pngComment(blob)
+	}
+	return nil, false
+}
+
+func jpegComment(blob []byte) ([]byte, bool) {
+	for i := 2; i+4 <= len(blob); {
+		if blob[i] != 0xFF {
+			return nil, false
+		}
+		marker := blob[i+1]
+		// Start of scan: image data follows, no more metadata segments
+		if marker == 0xDA {
+			return nil, false
+		}
+		length := int(binary.BigEndian.Uint16(blob[i+2 : i+4]))
+		if length < 2 || i+2+length > len(blob) {
+			return nil, false
+		}
+		if marker == 0xFE {
+			return blob[i+4 : i+2+length], true
+		}
+		i += 2 + length
+	}
+	return nil, false
+}
+
+func pngComment(blob []byte) ([]byte, bool) {
+	for i := len(pngSignature); i+8 <= len(blob); {
+		length := int(binary.BigEndian.Uint32(blob[i : i+4]))
+		chunkType := string(blob[i+4 : i+8])
+		if length < 0 || i+12+length > len(blob) {
+			return nil, false
+		}
+		data := blob[i+8 : i+8+length]
+		if chunkType == "tEXt" {
+			keyword, text, ok := bytes.Cut(data, []byte{0})
+			if ok && (string(keyword) == "Comment" || string(keyword) == "kyc-fake") {
+				return text, true
+			}
+		}
+		if chunkType == "IEND" {
+			return nil, false
+		}
+		i += 12 + length
+	}
+	return nil, false
+}
@@ -0,0 +1,34 @@
+package repo
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFakeDetectFaces(t *testing.T) {
+	r, err := NewFakeRepository("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		scenario string
+		want     int
+	}{
+		{`{}`, 1},
+		{`{"faces": 0}`, 0},
+		{`{"faces": 2}`, 2},
+		{`{"faces": -1}`, 0},
+	}
+
+	for _, tt := range tests {
+		faces, err := r.DetectFaces(context.Background(), []byte(tt.scenario))
+		if err != nil {
+			t.Errorf("DetectFaces(%s) error = %v", tt.scenario, err)
+			continue
+		}
+		if len(faces) != tt.want {
+			t.Errorf("DetectFaces(%s) = %d faces, want %d", tt.scenario, len(faces), tt.want)
+		}
+	}
+}
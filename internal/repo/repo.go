@@ -2,155 +2,107 @@ package repo
 
 import (
 	"context"
-	"errors"
-	"fmt"
-	"os"
+	"strings"
 	"time"
 
 	"github.com/SwanHtetAungPhyo/kyc-api/internal/models"
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
-	"github.com/aws/aws-sdk-go-v2/service/rekognition"
-	rtype "github.com/aws/aws-sdk-go-v2/service/rekognition/types"
-	"github.com/aws/aws-sdk-go-v2/service/textract"
-	textraTyp "github.com/aws/aws-sdk-go-v2/service/textract/types"
 )
 
-type AWSRepository interface {
-	AnalyzeID(ctx context.Context, idBlob []byte) (*textract.AnalyzeIDOutput, error)
-	DetectFaces(ctx context.Context, imageBlob []byte) (*rekognition.DetectFacesOutput, error)
-	CompareFaces(ctx context.Context, srcBlob, targetBlob []byte, threshold float32) (*rekognition.CompareFacesOutput, error)
-	RecordAttempt(ctx context.Context, email string, success bool) error
-	CheckIfProceed(ctx context.Context, email string) (bool, error)
+// DocumentAnalyzer extracts identity fields from an ID document image. It
+// returns a nil document when no identity document was found in the image.
+type DocumentAnalyzer interface {
+	AnalyzeID(ctx context.Context, idBlob []byte) (*models.IdentityDocument, error)
 }
 
-type awsRepository struct {
-	textractClient    *textract.Client
-	rekognitionClient *rekognition.Client
-	dynamoDBClient    *dynamodb.Client
+// FaceDetector finds the faces in an image
+type FaceDetector interface {
+	DetectFaces(ctx context.Context, imageBlob []byte) ([]models.FaceDetail, error)
 }
 
-func NewAWSRepository(accessKey, secretKey, region string) (AWSRepository, error) {
-	ctx := context.Background()
-	customCfg, err := config.LoadDefaultConfig(ctx,
-		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(accessKey, secretKey, "")),
-		config.WithRegion(region),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("unable to load SDK config: %w", err)
-	}
-
-	return &awsRepository{
-		textractClient:    textract.NewFromConfig(customCfg),
-		rekognitionClient: rekognition.NewFromConfig(customCfg),
-		dynamoDBClient:    dynamodb.NewFromConfig(customCfg),
-	}, nil
+// FaceComparer matches the face in the source image against the faces in the
+// target image. Only matches at or above threshold are returned.
+type FaceComparer interface {
+	CompareFaces(ctx context.Context, srcBlob, targetBlob []byte, threshold float32) ([]models.FaceMatch, error)
 }
 
-func (r *awsRepository) AnalyzeID(ctx context.Context, idBlob []byte) (*textract.AnalyzeIDOutput, error) {
-	input := &textract.AnalyzeIDInput{
-		DocumentPages: []textraTyp.Document{
-			{Bytes: idBlob},
-		},
-	}
-
-	result, err := r.textractClient.AnalyzeID(ctx, input)
-	if err != nil {
-		return nil, fmt.Errorf("textract analysis failed: %w", err)
-	}
-
-	return result, nil
+// AttemptStore keeps the attempt history of each identity. Methods take the
+// attempt key built by AttemptKey, which is stored as the record's email
+// attribute.
+type AttemptStore interface {
+	// RecordAttempt atomically appends an attempt to the key's history
+	RecordAttempt(ctx context.Context, email string, success bool) error
+	// GetAttempts returns the key's history, or nil if it has none
+	GetAttempts(ctx context.Context, email string) (*models.EmailRecord, error)
+	// Reserve atomically takes the key for the verification identified by
+	// reservation until the lease expires. A key still held by the same
+	// reservation is taken over, so a job restarted after a crash can resume.
+	// It fails with a *ConflictError when the key is verified or reserved by
+	// another verification.
+	Reserve(ctx context.Context, email, reservation string, lease time.Duration) error
+	// Release frees the key if the reservation still holds it
+	Release(ctx context.Context, email, reservation string) error
 }
 
-func (r *awsRepository) DetectFaces(ctx context.Context, imageBlob []byte) (*rekognition.DetectFacesOutput, error) {
-	input := &rekognition.DetectFacesInput{
-		Image: &rtype.Image{
-			Bytes: imageBlob,
-		},
-		Attributes: []rtype.Attribute{
-			rtype.AttributeDefault,
-			rtype.AttributeAll,
-		},
-	}
-
-	result, err := r.rekognitionClient.DetectFaces(ctx, input)
-	if err != nil {
-		return nil, fmt.Errorf("face detection failed: %w", err)
-	}
-
-	return result, nil
+// VerificationStore keeps the audit record of every verification
+type VerificationStore interface {
+	// SaveVerification stores a new record. Records are never updated.
+	SaveVerification(ctx context.Context, record *models.VerificationRecord) error
+	// GetVerification returns the tenant's record with the ID, or nil if
+	// the tenant has none
+	GetVerification(ctx context.Context, tenantID, id string) (*models.VerificationRecord, error)
+	// ListVerifications returns a page of the records the query selects
+	ListVerifications(ctx context.Context, query VerificationQuery) (*VerificationPage, error)
 }
 
-func (r *awsRepository) CompareFaces(ctx context.Context, srcBlob, targetBlob []byte, threshold float32) (*rekognition.CompareFacesOutput, error) {
-	input := &rekognition.CompareFacesInput{
-		SourceImage: &rtype.Image{
-			Bytes: srcBlob,
-		},
-		TargetImage: &rtype.Image{
-			Bytes: targetBlob,
-		},
-		SimilarityThreshold: aws.Float32(threshold),
+// AttemptKey partitions attempts by tenant so tenants cannot see or block
+// each other's users. Attempts without a tenant keep the bare email they were
+// recorded under before tenants existed. Neither tenant IDs nor emails
+// contain "#", so keys cannot collide.
+func AttemptKey(tenantID, email string) string {
+	if tenantID == "" {
+		return email
 	}
-
-	result, err := r.rekognitionClient.CompareFaces(ctx, input)
-	if err != nil {
-		return nil, fmt.Errorf("face comparison failed: %w", err)
-	}
-
-	return result, nil
+	return tenantID + "#" + email
 }
 
-func (r *awsRepository) RecordAttempt(ctx context.Context, email string, success bool) error {
-	record := models.EmailRecord{
-		Email:       email,
-		AttemptedAt: time.Now(),
-		Processed:   success,
+// attemptTenant returns the tenant of an attempt key, which identifies it in
+// logs without the email
+func attemptTenant(key string) string {
+	tenantID, _, found := strings.Cut(key, "#")
+	if !found {
+		return ""
 	}
-
-	item, err := attributevalue.MarshalMap(record)
-	if err != nil {
-		return fmt.Errorf("failed to put the item: %s", err.Error())
-	}
-	tableName := os.Getenv("KYC_RECORD")
-
-	_, err = r.dynamoDBClient.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName:           &tableName,
-		Item:                item,
-		ConditionExpression: aws.String("attribute_not_exists(email)"),
-	})
-
-	var ccfe *types.ConditionalCheckFailedException
-	if errors.Is(err, ccfe) {
-		return errors.New("already processed")
-	}
-	return nil
+	return tenantID
 }
 
-func (r *awsRepository) CheckIfProceed(ctx context.Context, email string) (bool, error) {
-	tableName := os.Getenv("KYC_RECORD")
-	result, err := r.dynamoDBClient.GetItem(ctx, &dynamodb.GetItemInput{
-		TableName: aws.String(tableName),
-		Key: map[string]types.AttributeValue{
-			"email": &types.AttributeValueMemberS{
-				Value: email,
-			},
-		},
-	})
-	if err != nil {
-		return false, fmt.Errorf("failed to get item: %s", err.Error())
-	}
+// AWSRepository is implemented by repositories that provide every
+// dependency of the KYC service
+type AWSRepository interface {
+	DocumentAnalyzer
+	FaceDetector
+	FaceComparer
+	AttemptStore
+	VerificationStore
+}
 
-	if result.Item == nil {
-		return false, nil
-	}
+// Providers bundles the dependencies of the KYC service so each can come
+// from a different implementation
+type Providers struct {
+	Documents DocumentAnalyzer
+	Faces     FaceDetector
+	Comparer  FaceComparer
+	Attempts  AttemptStore
+	// Verifications keeps the verification records
+	Verifications VerificationStore
+}
 
-	var record models.EmailRecord
-	if err := attributevalue.UnmarshalMap(result.Item, &record); err != nil {
-		return false, fmt.Errorf("failed to unmarshal data: %s", err.Error())
+// ProvidersFrom uses a single repository for every provider
+func ProvidersFrom(r AWSRepository) Providers {
+	return Providers{
+		Documents:     r,
+		Faces:         r,
+		Comparer:      r,
+		Attempts:      r,
+		Verifications: r,
 	}
-	return record.Processed, nil
 }
@@ -0,0 +1,68 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAttemptKey(t *testing.T) {
+	tests := []struct {
+		tenantID string
+		email    string
+		want     string
+	}{
+		{"acme", "jane@example.com", "acme#jane@example.com"},
+		{"", "jane@example.com", "jane@example.com"},
+	}
+
+	for _, tt := range tests {
+		if got := AttemptKey(tt.tenantID, tt.email); got != tt.want {
+			t.Errorf("AttemptKey(%q, %q) = %q, want %q", tt.tenantID, tt.email, got, tt.want)
+		}
+		if got := attemptTenant(tt.want); got != tt.tenantID {
+			t.Errorf("attemptTenant(%q) = %q, want %q", tt.want, got, tt.tenantID)
+		}
+	}
+}
+
+func TestReserveConcurrently(t *testing.T) {
+	fake, err := NewFakeRepository("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	boltStore, _ := newBoltAttemptStore(t)
+
+	stores := map[string]AttemptStore{"fake": fake, "bolt": boltStore}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			start := make(chan struct{})
+			errs := make(chan error, 2)
+			for _, reservation := range []string{"r1", "r2"} {
+				go func() {
+					<-start
+					errs <- store.Reserve(context.Background(), "jane@example.com", reservation, time.Minute)
+				}()
+			}
+			close(start)
+
+			var reserved, conflicted int
+			for range 2 {
+				err := <-errs
+				var conflict *ConflictError
+				switch {
+				case err == nil:
+					reserved++
+				case errors.As(err, &conflict) && conflict.InProgress:
+					conflicted++
+				default:
+					t.Errorf("Reserve() error = %v, want success or a conflict", err)
+				}
+			}
+			if reserved != 1 || conflicted != 1 {
+				t.Errorf("got %d reservations and %d conflicts, want exactly one of each", reserved, conflicted)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/SwanHtetAungPhyo/kyc-api/pkg/config"
+	"github.com/SwanHtetAungPhyo/kyc-api/pkg/logger"
+)
+
+// ErrRetryQueueFull is returned by RetryingAttemptStore.RecordAttempt when a
+// failed write cannot be queued for retry
+var ErrRetryQueueFull = errors.New("attempt retry queue is full")
+
+// RetryingAttemptStore wraps an AttemptStore so that attempts which could not
+// be written are retried in the background with exponential backoff instead
+// of failing the caller. Until a retry succeeds the attempt is missing from
+// the history, so it does not count towards duplicate or failure limits.
+type RetryingAttemptStore struct {
+	AttemptStore
+
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	pending        chan struct{}
+	logger         logger.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewRetryingAttemptStore(store AttemptStore, log logger.Logger, cfg config.AttemptRetryConfig) *RetryingAttemptStore {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &RetryingAttemptStore{
+		AttemptStore:   store,
+		maxAttempts:    cfg.MaxAttempts,
+		initialBackoff: cfg.InitialBackoff,
+		maxBackoff:     cfg.MaxBackoff,
+		pending:        make(chan struct{}, cfg.MaxPending),
+		logger:         log,
+		ctx:            ctx,
+		cancel:         cancel,
+	}
+}
+
+// RecordAttempt writes the attempt, queueing it for retry if the write
+// fails. Conflicts are returned as is, since retrying can't resolve them.
+func (s *RetryingAttemptStore) RecordAttempt(ctx context.Context, email string, success bool) error {
+	err := s.AttemptStore.RecordAttempt(ctx, email, success)
+	var conflict *ConflictError
+	if err == nil || errors.As(err, &conflict) {
+		return err
+	}
+
+	select {
+	case s.pending <- struct{}{}:
+	default:
+		return errors.Join(ErrRetryQueueFull, err)
+	}
+
+	s.logger.WithError(err).WithField("tenant_id", attemptTenant(email)).Error("Failed to record attempt, retrying in the background")
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() { <-s.pending }()
+		s.retry(email, success)
+	}()
+	return nil
+}
+
+func (s *RetryingAttemptStore) retry(email string, success bool) {
+	backoff := s.initialBackoff
+	for attempt := 1; attempt <= s.maxAttempts; attempt++ {
+		select {
+		case <-time.After(backoff):
+		case <-s.ctx.Done():
+			s.logger.WithField("tenant_id", attemptTenant(email)).Error("Attempt lost, shut down before it could be recorded")
+			return
+		}
+		backoff = min(backoff*2, s.maxBackoff)
+
+		err := s.AttemptStore.RecordAttempt(s.ctx, email, success)
+		var conflict *ConflictError
+		switch {
+		case err == nil:
+			s.logger.WithField("tenant_id", attemptTenant(email)).Info("Recorded attempt after retrying")
+			return
+		case errors.As(err, &conflict):
+			s.logger.WithError(err).WithField("tenant_id", attemptTenant(email)).Error("Dropped retried attempt of a verified identity")
+			return
+		}
+		s.logger.WithError(err).WithField("retry", attempt).Error("Failed to record attempt")
+	}
+
+	s.logger.WithField("tenant_id", attemptTenant(email)).Error("Attempt lost, giving up after retrying")
+}
+
+// Close abandons pending retries and waits for in-flight writes
+func (s *RetryingAttemptStore) Close() {
+	s.cancel()
+	s.wg.Wait()
+}
@@ -0,0 +1,170 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SwanHtetAungPhyo/kyc-api/pkg/config"
+	"github.com/SwanHtetAungPhyo/kyc-api/pkg/logger"
+)
+
+// flakyStore fails RecordAttempt with errs, in order, before passing writes
+// on to the wrapped store
+type flakyStore struct {
+	AttemptStore
+
+	mu    sync.Mutex
+	errs  []error
+	calls int
+}
+
+func (s *flakyStore) RecordAttempt(ctx context.Context, email string, success bool) error {
+	s.mu.Lock()
+	s.calls++
+	var err error
+	if len(s.errs) > 0 {
+		err, s.errs = s.errs[0], s.errs[1:]
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+	return s.AttemptStore.RecordAttempt(ctx, email, success)
+}
+
+func (s *flakyStore) Calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+func newRetryTest(t *testing.T, errs ...error) (*RetryingAttemptStore, *flakyStore) {
+	t.Helper()
+
+	fake, err := NewFakeRepository("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	flaky := &flakyStore{AttemptStore: fake, errs: errs}
+	store := NewRetryingAttemptStore(flaky, logger.NewLogger(), config.AttemptRetryConfig{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     2 * time.Millisecond,
+		MaxPending:     10,
+	})
+	t.Cleanup(store.Close)
+	return store, flaky
+}
+
+// waitForCalls waits until the store has seen n writes
+func waitForCalls(t *testing.T, flaky *flakyStore, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for flaky.Calls() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("RecordAttempt() called %d times, want %d", flaky.Calls(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRetryingAttemptStoreRetries(t *testing.T) {
+	tests := []struct {
+		name string
+		errs []error
+	}{
+		{"throttled", []error{ErrThrottled}},
+		{"unavailable", []error{ErrUnavailable, ErrUnavailable}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store, flaky := newRetryTest(t, tt.errs...)
+
+			if err := store.RecordAttempt(ctx, "jane@example.com", false); err != nil {
+				t.Fatalf("RecordAttempt() error = %v, want the write queued for retry", err)
+			}
+			waitForCalls(t, flaky, len(tt.errs)+1)
+			store.Close()
+
+			record, err := store.GetAttempts(ctx, "jane@example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if record == nil || len(record.History()) != 1 {
+				t.Errorf("GetAttempts() = %+v, want the retried attempt recorded once", record)
+			}
+		})
+	}
+}
+
+func TestRetryingAttemptStoreReturnsConflict(t *testing.T) {
+	store, flaky := newRetryTest(t, &ConflictError{Key: "jane@example.com"})
+
+	err := store.RecordAttempt(context.Background(), "jane@example.com", true)
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("RecordAttempt() error = %v, want the conflict", err)
+	}
+	store.Close()
+	if calls := flaky.Calls(); calls != 1 {
+		t.Errorf("RecordAttempt() called %d times, want the conflict not retried", calls)
+	}
+}
+
+func TestRetryingAttemptStoreGivesUp(t *testing.T) {
+	ctx := context.Background()
+	errs := make([]error, 10)
+	for i := range errs {
+		errs[i] = ErrUnavailable
+	}
+	store, flaky := newRetryTest(t, errs...)
+
+	if err := store.RecordAttempt(ctx, "jane@example.com", false); err != nil {
+		t.Fatalf("RecordAttempt() error = %v, want the write queued for retry", err)
+	}
+	// The first write and MaxAttempts retries
+	waitForCalls(t, flaky, 4)
+	time.Sleep(20 * time.Millisecond)
+	store.Close()
+
+	if calls := flaky.Calls(); calls != 4 {
+		t.Errorf("RecordAttempt() called %d times, want 4", calls)
+	}
+	record, err := store.GetAttempts(ctx, "jane@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record != nil {
+		t.Errorf("GetAttempts() = %+v, want the attempt lost", record)
+	}
+}
+
+func TestRetryingAttemptStoreQueueFull(t *testing.T) {
+	fake, err := NewFakeRepository("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	flaky := &flakyStore{AttemptStore: fake, errs: []error{ErrUnavailable, ErrUnavailable}}
+	store := NewRetryingAttemptStore(flaky, logger.NewLogger(), config.AttemptRetryConfig{
+		MaxAttempts:    1,
+		InitialBackoff: time.Hour,
+		MaxBackoff:     time.Hour,
+		MaxPending:     1,
+	})
+	defer store.Close()
+
+	ctx := context.Background()
+	if err := store.RecordAttempt(ctx, "a@example.com", false); err != nil {
+		t.Fatalf("RecordAttempt() error = %v, want the write queued for retry", err)
+	}
+	if err := store.RecordAttempt(ctx, "b@example.com", false); !errors.Is(err, ErrRetryQueueFull) || !errors.Is(err, ErrUnavailable) {
+		t.Errorf("RecordAttempt() error = %v, want ErrRetryQueueFull with the write error", err)
+	}
+}
@@ -0,0 +1,111 @@
+package repo
+
+import (
+	"strings"
+
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/models"
+	"github.com/aws/aws-sdk-go-v2/service/textract"
+	textraTyp "github.com/aws/aws-sdk-go-v2/service/textract/types"
+)
+
+// Textract AnalyzeID field types we extract from the identity document
+const (
+	fieldIDType         = "ID_TYPE"
+	fieldFirstName      = "FIRST_NAME"
+	fieldMiddleName     = "MIDDLE_NAME"
+	fieldLastName       = "LAST_NAME"
+	fieldDateOfBirth    = "DATE_OF_BIRTH"
+	fieldDocumentNumber = "DOCUMENT_NUMBER"
+	fieldDateOfIssue    = "DATE_OF_ISSUE"
+	fieldExpirationDate = "EXPIRATION_DATE"
+	fieldAddress        = "ADDRESS"
+	fieldCity           = "CITY_IN_ADDRESS"
+	fieldZipCode        = "ZIP_CODE_IN_ADDRESS"
+	fieldStateInAddress = "STATE_IN_ADDRESS"
+	fieldStateName      = "STATE_NAME"
+	fieldMRZCode        = "MRZ_CODE"
+)
+
+// parseIdentityDocument normalizes the first identity document in an AnalyzeID
+// response into our own model. It returns nil when Textract found no document.
+func parseIdentityDocument(output *textract.AnalyzeIDOutput) *models.IdentityDocument {
+	if output == nil || len(output.IdentityDocuments) == 0 {
+		return nil
+	}
+
+	fields := make(map[string]*models.DocumentField)
+	for _, f := range output.IdentityDocuments[0].IdentityDocumentFields {
+		if f.Type == nil || f.Type.Text == nil {
+			continue
+		}
+		if field := toDocumentField(f.ValueDetection); field != nil {
+			fields[strings.ToUpper(*f.Type.Text)] = field
+		}
+	}
+
+	doc := &models.IdentityDocument{
+		DocumentType:   fields[fieldIDType],
+		FirstName:      fields[fieldFirstName],
+		MiddleName:     fields[fieldMiddleName],
+		LastName:       fields[fieldLastName],
+		DateOfBirth:    fields[fieldDateOfBirth],
+		DocumentNumber: fields[fieldDocumentNumber],
+		DateOfIssue:    fields[fieldDateOfIssue],
+		ExpirationDate: fields[fieldExpirationDate],
+		Address:        fields[fieldAddress],
+		IssuingState:   fields[fieldStateName],
+		MRZCode:        fields[fieldMRZCode],
+	}
+
+	if doc.Address == nil {
+		doc.Address = joinFields(fields[fieldCity], fields[fieldStateInAddress], fields[fieldZipCode])
+	}
+
+	return doc
+}
+
+func toDocumentField(detection *textraTyp.AnalyzeIDDetections) *models.DocumentField {
+	if detection == nil || detection.Text == nil {
+		return nil
+	}
+
+	value := strings.TrimSpace(*detection.Text)
+	if value == "" {
+		return nil
+	}
+
+	field := &models.DocumentField{Value: value}
+	if detection.Confidence != nil {
+		field.Confidence = *detection.Confidence
+	}
+	if detection.NormalizedValue != nil && detection.NormalizedValue.Value != nil {
+		field.Normalized = *detection.NormalizedValue.Value
+	}
+
+	return field
+}
+
+// joinFields combines address parts into a single field, reporting the lowest
+// confidence among the parts that were present.
+func joinFields(parts ...*models.DocumentField) *models.DocumentField {
+	var values []string
+	var confidence float32
+	for _, part := range parts {
+		if part == nil {
+			continue
+		}
+		if len(values) == 0 || part.Confidence < confidence {
+			confidence = part.Confidence
+		}
+		values = append(values, part.Value)
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	return &models.DocumentField{
+		Value:      strings.Join(values, ", "),
+		Confidence: confidence,
+	}
+}
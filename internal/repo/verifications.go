@@ -0,0 +1,115 @@
+package repo
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/models"
+)
+
+// ErrInvalidCursor is returned by ListVerifications for a cursor it did not
+// issue
+var ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+// VerificationQuery selects the verification records of one tenant. Every
+// filter besides TenantID is optional.
+type VerificationQuery struct {
+	TenantID string
+	Email    string
+	Status   models.VerificationStatus
+	// From and To bound the start of the verification: records started at
+	// or after From and before To are returned
+	From time.Time
+	To   time.Time
+	// Limit is the maximum number of records per page
+	Limit int
+	// Cursor resumes after the last record of a previous page
+	Cursor string
+}
+
+// VerificationPage is one page of records, newest first
+type VerificationPage struct {
+	Records []models.VerificationRecord
+	// NextCursor fetches the next page. It is only set when more records
+	// follow, so the next page is never empty.
+	NextCursor string
+}
+
+// pageCursor is the position after the last record of a page
+type pageCursor struct {
+	Created string `json:"c"`
+	ID      string `json:"i"`
+}
+
+func encodeCursor(record *models.VerificationRecord) string {
+	data, _ := json.Marshal(pageCursor{Created: createdKey(record.StartedAt), ID: record.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(cursor string) (*pageCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var position pageCursor
+	if err := json.Unmarshal(data, &position); err != nil || position.Created == "" || position.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &position, nil
+}
+
+// createdKey formats t with a fixed width, so keys sort in time order
+func createdKey(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000000000Z")
+}
+
+// createdRange returns the inclusive key range of the query's time bounds
+func createdRange(q VerificationQuery) (string, string) {
+	from, to := createdKey(time.Time{}), "9999-12-31T23:59:59.999999999Z"
+	if !q.From.IsZero() {
+		from = createdKey(q.From)
+	}
+	if !q.To.IsZero() {
+		to = createdKey(q.To.Add(-time.Nanosecond))
+	}
+	return from, to
+}
+
+// tenantPartition and emailPartition name the index partitions a record is
+// listed under. Records without a tenant get partitions of their own.
+func tenantPartition(tenantID string) string {
+	return "t#" + tenantID
+}
+
+func emailPartition(tenantID, email string) string {
+	return "e#" + tenantID + "#" + email
+}
+
+// partition is the index partition that holds every record the query
+// selects
+func (q VerificationQuery) partition() string {
+	if q.Email != "" {
+		return emailPartition(q.TenantID, q.Email)
+	}
+	return tenantPartition(q.TenantID)
+}
+
+// matches applies the filters that are not covered by the index partition
+func (q VerificationQuery) matches(record *models.VerificationRecord) bool {
+	return q.Status == "" || record.Status == q.Status
+}
+
+// newPage returns the first limit records, with a cursor when more follow
+func newPage(records []models.VerificationRecord, limit int) *VerificationPage {
+	page := &VerificationPage{Records: records}
+	if len(records) > limit {
+		page.Records = records[:limit]
+		page.NextCursor = encodeCursor(&page.Records[limit-1])
+	}
+	return page
+}
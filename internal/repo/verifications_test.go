@@ -0,0 +1,79 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/models"
+)
+
+func TestDecodeCursor(t *testing.T) {
+	record := &models.VerificationRecord{ID: "v1", StartedAt: time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)}
+	position, err := decodeCursor(encodeCursor(record))
+	if err != nil {
+		t.Fatalf("decodeCursor() error = %v", err)
+	}
+	if position.ID != "v1" || position.Created != createdKey(record.StartedAt) {
+		t.Errorf("decodeCursor() = %+v, want the record's position", position)
+	}
+
+	if position, err := decodeCursor(""); position != nil || err != nil {
+		t.Errorf("decodeCursor(\"\") = %v, %v, want no position", position, err)
+	}
+	for _, cursor := range []string{"not base64!", "bm90IGpzb24", "e30"} {
+		if _, err := decodeCursor(cursor); err != ErrInvalidCursor {
+			t.Errorf("decodeCursor(%q) error = %v, want %v", cursor, err, ErrInvalidCursor)
+		}
+	}
+}
+
+func TestListVerificationsPages(t *testing.T) {
+	ctx := context.Background()
+	r, err := NewFakeRepository("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	for i := range 5 {
+		record := &models.VerificationRecord{ID: fmt.Sprintf("v%d", i), TenantID: "acme", StartedAt: start.Add(time.Duration(i) * time.Minute)}
+		if err := r.SaveVerification(ctx, record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var ids []string
+	var pages int
+	query := VerificationQuery{TenantID: "acme", Limit: 2}
+	for {
+		page, err := r.ListVerifications(ctx, query)
+		if err != nil {
+			t.Fatalf("ListVerifications() error = %v", err)
+		}
+		pages++
+		if len(page.Records) == 0 {
+			t.Fatal("ListVerifications() returned an empty page")
+		}
+		for _, record := range page.Records {
+			ids = append(ids, record.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+
+	if want := "[v4 v3 v2 v1 v0]"; fmt.Sprint(ids) != want || pages != 3 {
+		t.Errorf("listed %v in %d pages, want %s in 3", ids, pages, want)
+	}
+
+	page, err := r.ListVerifications(ctx, VerificationQuery{TenantID: "acme", Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Records) != 5 || page.NextCursor != "" {
+		t.Errorf("full last page = %d records with cursor %q, want 5 without a cursor", len(page.Records), page.NextCursor)
+	}
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/models"
+	"github.com/SwanHtetAungPhyo/kyc-api/pkg/config"
+)
+
+// checkAttemptLimit rejects identities locked by too many failed attempts.
+// An identity is locked when its last failure brought the failures within
+// the retry window to the limit, and stays locked for the lockout period
+// after that failure. Each further failure after a lockout locks it again
+// until older failures leave the window.
+func checkAttemptLimit(record *models.EmailRecord, retry config.RetryConfig, now time.Time) models.CheckResult {
+	if retry.MaxFailures <= 0 {
+		return skippedCheck(models.CheckAttemptLimit, "No attempt limit configured")
+	}
+
+	var history []models.Attempt
+	if record != nil {
+		history = record.History()
+	}
+
+	recent := failuresSince(history, now.Add(-retry.Window))
+	lastFailure, ok := latestFailure(history)
+	if !ok || failuresSince(history, lastFailure.Add(-retry.Window)) < retry.MaxFailures {
+		return passedCheck(models.CheckAttemptLimit, recent, retry.MaxFailures)
+	}
+
+	if retry.Lockout == 0 {
+		return failedCheck(models.CheckAttemptLimit, models.ReasonTooManyAttempts,
+			"Too many failed attempts, this identity requires manual review", recent, retry.MaxFailures)
+	}
+	lockedUntil := lastFailure.Add(retry.Lockout)
+	if now.Before(lockedUntil) {
+		return failedCheck(models.CheckAttemptLimit, models.ReasonTooManyAttempts,
+			fmt.Sprintf("Too many failed attempts, retry after %s", lockedUntil.UTC().Format(time.RFC3339)),
+			recent, retry.MaxFailures)
+	}
+	return passedCheck(models.CheckAttemptLimit, recent, retry.MaxFailures)
+}
+
+// failuresSince counts the failed attempts after since
+func failuresSince(history []models.Attempt, since time.Time) int {
+	count := 0
+	for _, attempt := range history {
+		if !attempt.Success && attempt.At.After(since) {
+			count++
+		}
+	}
+	return count
+}
+
+func latestFailure(history []models.Attempt) (time.Time, bool) {
+	for i := len(history) - 1; i >= 0; i-- {
+		if !history[i].Success {
+			return history[i].At, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/models"
+	"github.com/SwanHtetAungPhyo/kyc-api/pkg/config"
+)
+
+// failures returns a record with a failed attempt at each offset from now
+func failures(now time.Time, offsets ...time.Duration) *models.EmailRecord {
+	record := &models.EmailRecord{}
+	for _, offset := range offsets {
+		record.Record(now.Add(offset), false)
+	}
+	return record
+}
+
+func TestCheckAttemptLimit(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	retry := config.RetryConfig{MaxFailures: 3, Window: 24 * time.Hour, Lockout: 12 * time.Hour}
+
+	tests := []struct {
+		name   string
+		record *models.EmailRecord
+		retry  config.RetryConfig
+		want   models.CheckStatus
+	}{
+		{"no limit", failures(now, -3*time.Hour, -2*time.Hour, -time.Hour), config.RetryConfig{}, models.CheckSkipped},
+		{"no history", nil, retry, models.CheckPassed},
+		{"below the limit", failures(now, -2*time.Hour, -time.Hour), retry, models.CheckPassed},
+		{"at the limit", failures(now, -3*time.Hour, -2*time.Hour, -time.Hour), retry, models.CheckFailed},
+		{"lockout over", failures(now, -15*time.Hour, -14*time.Hour, -13*time.Hour), retry, models.CheckPassed},
+		{"failures spread beyond the window", failures(now, -50*time.Hour, -26*time.Hour, -time.Hour), retry, models.CheckPassed},
+		{"failure after a lockout locks again", failures(now, -20*time.Hour, -19*time.Hour, -18*time.Hour, -time.Hour), retry, models.CheckFailed},
+		{"manual review", failures(now, -300*time.Hour, -299*time.Hour, -298*time.Hour), config.RetryConfig{MaxFailures: 3, Window: 24 * time.Hour}, models.CheckFailed},
+		{"successes do not count", func() *models.EmailRecord {
+			record := failures(now, -3*time.Hour, -2*time.Hour)
+			record.Record(now.Add(-time.Hour), true)
+			return record
+		}(), retry, models.CheckPassed},
+		{"record without history", &models.EmailRecord{AttemptedAt: now.Add(-time.Hour)}, config.RetryConfig{MaxFailures: 1, Window: 24 * time.Hour, Lockout: 2 * time.Hour}, models.CheckFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := checkAttemptLimit(tt.record, tt.retry, now)
+			if got.Status != tt.want {
+				t.Errorf("checkAttemptLimit() = %+v, want status %q", got, tt.want)
+			}
+			if got.Status == models.CheckFailed && got.Reason != models.ReasonTooManyAttempts {
+				t.Errorf("Reason = %q, want %q", got.Reason, models.ReasonTooManyAttempts)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/models"
+	"github.com/SwanHtetAungPhyo/kyc-api/pkg/config"
+)
+
+func passedCheck(name models.CheckName, observed, threshold interface{}) models.CheckResult {
+	return models.CheckResult{
+		Name:      name,
+		Status:    models.CheckPassed,
+		Observed:  observed,
+		Threshold: threshold,
+	}
+}
+
+func failedCheck(name models.CheckName, reason models.ReasonCode, message string, observed, threshold interface{}) models.CheckResult {
+	return models.CheckResult{
+		Name:      name,
+		Status:    models.CheckFailed,
+		Observed:  observed,
+		Threshold: threshold,
+		Reason:    reason,
+		Message:   message,
+	}
+}
+
+func skippedCheck(name models.CheckName, message string) models.CheckResult {
+	return models.CheckResult{
+		Name:    name,
+		Status:  models.CheckSkipped,
+		Message: message,
+	}
+}
+
+// runCheck runs check unless the verification profile disables it, in which
+// case the check is reported as skipped
+func runCheck(policy config.Policy, name models.CheckName, check func() models.CheckResult) models.CheckResult {
+	if policy.Disables(string(name)) {
+		return skippedCheck(name, fmt.Sprintf("Disabled by verification profile %q", policy.Profile))
+	}
+	return check()
+}
+
+// completeChecks orders checks by models.CheckOrder and marks every check
+// that did not run as skipped
+func completeChecks(checks []models.CheckResult) []models.CheckResult {
+	byName := make(map[models.CheckName]models.CheckResult, len(checks))
+	for _, check := range checks {
+		byName[check.Name] = check
+	}
+
+	complete := make([]models.CheckResult, 0, len(models.CheckOrder))
+	for _, name := range models.CheckOrder {
+		check, ok := byName[name]
+		if !ok {
+			check = skippedCheck(name, "")
+		}
+		complete = append(complete, check)
+	}
+	return complete
+}
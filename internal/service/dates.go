@@ -0,0 +1,171 @@
+package service
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/models"
+)
+
+// normalizedDateLayout is the layout Textract uses for NormalizedValue dates
+const normalizedDateLayout = "2006-01-02T15:04:05"
+
+// textDateLayouts covers the unambiguous formats printed on ID documents.
+// Month names are matched case-insensitively by time.Parse.
+var textDateLayouts = []string{
+	"2006-01-02",
+	"2006/01/02",
+	"2006.01.02",
+	"20060102",
+	"02 Jan 2006",
+	"2 Jan 2006",
+	"02 January 2006",
+	"2 January 2006",
+	"02-Jan-2006",
+	"02 Jan 06",
+	"02Jan2006",
+	"02Jan06",
+	"Jan 2 2006",
+	"Jan 2, 2006",
+	"January 2, 2006",
+	"January 2 2006",
+}
+
+var (
+	// bilingualMonth matches passport dates such as "15 MAR/MARS 2025"
+	bilingualMonth = regexp.MustCompile(`^(\d{1,2})\s*([A-Za-z]{3,})\s*/\s*\S+\s+(\d{2,4})$`)
+	// numericDate matches day/month ordering ambiguous dates such as 03/04/2025
+	numericDate = regexp.MustCompile(`^(\d{1,2})[./\- ](\d{1,2})[./\- ](\d{2}|\d{4})$`)
+)
+
+// parseDocumentDate parses a date field extracted from an ID document. The
+// Textract normalized value is preferred; the raw text is parsed as a
+// fallback. Purely numeric dates are read month-first when monthFirst is set
+// (US documents) and day-first otherwise, unless only one reading is valid.
+func parseDocumentDate(field *models.DocumentField, monthFirst bool) (time.Time, bool) {
+	if field == nil {
+		return time.Time{}, false
+	}
+
+	if field.Normalized != "" {
+		if t, err := time.Parse(normalizedDateLayout, field.Normalized); err == nil {
+			return t, true
+		}
+	}
+
+	return parseDateText(field.Value, monthFirst, expandTwoDigitYear)
+}
+
+// parseBirthDate parses a date of birth like parseDocumentDate, except that
+// two-digit years are never placed after now: 01/02/65 is 1965, not 2065. A
+// normalized value in the future is distrusted for the same reason.
+func parseBirthDate(field *models.DocumentField, monthFirst bool, now time.Time) (time.Time, bool) {
+	if field == nil {
+		return time.Time{}, false
+	}
+
+	if field.Normalized != "" {
+		if t, err := time.Parse(normalizedDateLayout, field.Normalized); err == nil && !t.After(now) {
+			return t, true
+		}
+	}
+
+	return parseDateText(field.Value, monthFirst, func(y int) int {
+		return expandBirthYear(y, now)
+	})
+}
+
+func parseDateText(value string, monthFirst bool, expand func(int) int) (time.Time, bool) {
+	value = strings.Join(strings.Fields(value), " ")
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	if m := bilingualMonth.FindStringSubmatch(value); m != nil {
+		value = m[1] + " " + m[2] + " " + m[3]
+	}
+
+	for _, layout := range textDateLayouts {
+		t, err := time.Parse(layout, value)
+		if err != nil {
+			continue
+		}
+		// time.Parse applies its own pivot to two-digit years
+		if !strings.Contains(layout, "2006") {
+			return dateOf(expand(t.Year()%100), int(t.Month()), t.Day())
+		}
+		return t, true
+	}
+
+	if m := numericDate.FindStringSubmatch(value); m != nil {
+		return parseNumericDate(m[1], m[2], m[3], monthFirst, expand)
+	}
+
+	return time.Time{}, false
+}
+
+func parseNumericDate(first, second, year string, monthFirst bool, expand func(int) int) (time.Time, bool) {
+	a, _ := strconv.Atoi(first)
+	b, _ := strconv.Atoi(second)
+	y, _ := strconv.Atoi(year)
+	if len(year) == 2 {
+		y = expand(y)
+	}
+
+	day, month := a, b
+	if monthFirst {
+		day, month = b, a
+	}
+	// Fall back to the other ordering when the preferred one is impossible
+	if month > 12 && day <= 12 {
+		day, month = month, day
+	}
+
+	return dateOf(y, month, day)
+}
+
+// dateOf returns the date at midnight UTC, or false if it does not exist
+func dateOf(year, month, day int) (time.Time, bool) {
+	t := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if t.Day() != day || int(t.Month()) != month {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// expandTwoDigitYear follows the same pivot as time.Parse: 69-99 are 19xx.
+// It suits expiry and issue dates, which lie within a few decades of now.
+func expandTwoDigitYear(y int) int {
+	if y >= 69 {
+		return 1900 + y
+	}
+	return 2000 + y
+}
+
+// expandBirthYear places a two-digit year in the century before now, as
+// nobody can be born after the current year
+func expandBirthYear(y int, now time.Time) int {
+	year := now.Year() - now.Year()%100 + y
+	if year > now.Year() {
+		year -= 100
+	}
+	return year
+}
+
+// startOfDay truncates t to midnight UTC so document dates compare by calendar day
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// ageOn returns the number of whole years between dob and now
+func ageOn(dob, now time.Time) int {
+	dob, now = startOfDay(dob), startOfDay(now)
+	age := now.Year() - dob.Year()
+	if now.Month() < dob.Month() || (now.Month() == dob.Month() && now.Day() < dob.Day()) {
+		age--
+	}
+	return age
+}
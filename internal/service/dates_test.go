@@ -0,0 +1,147 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/models"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestParseDocumentDate(t *testing.T) {
+	tests := []struct {
+		name       string
+		field      *models.DocumentField
+		monthFirst bool
+		want       time.Time
+		ok         bool
+	}{
+		{"missing field", nil, false, time.Time{}, false},
+		{"normalized value", &models.DocumentField{Value: "garbage", Normalized: "2030-05-17T00:00:00"}, false, date(2030, 5, 17), true},
+		{"ISO date", &models.DocumentField{Value: "2030-05-17"}, false, date(2030, 5, 17), true},
+		{"month name", &models.DocumentField{Value: "17 May 2030"}, false, date(2030, 5, 17), true},
+		{"bilingual month", &models.DocumentField{Value: "15 MAR/MARS 2025"}, false, date(2025, 3, 15), true},
+		{"compact passport date", &models.DocumentField{Value: "15MAR25"}, false, date(2025, 3, 15), true},
+		{"extra whitespace", &models.DocumentField{Value: "  17   May  2030 "}, false, date(2030, 5, 17), true},
+		{"day first", &models.DocumentField{Value: "03/04/2030"}, false, date(2030, 4, 3), true},
+		{"month first", &models.DocumentField{Value: "03/04/2030"}, true, date(2030, 3, 4), true},
+		{"impossible month first", &models.DocumentField{Value: "17/04/2030"}, true, date(2030, 4, 17), true},
+		{"two-digit year", &models.DocumentField{Value: "01.02.30"}, false, date(2030, 2, 1), true},
+		{"two-digit year pivot", &models.DocumentField{Value: "01.02.69"}, false, date(1969, 2, 1), true},
+		{"nonexistent day", &models.DocumentField{Value: "31/02/2030"}, false, time.Time{}, false},
+		{"unreadable", &models.DocumentField{Value: "N/A"}, false, time.Time{}, false},
+		{"empty", &models.DocumentField{}, false, time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseDocumentDate(tt.field, tt.monthFirst)
+			if ok != tt.ok || !got.Equal(tt.want) {
+				t.Errorf("parseDocumentDate() = %v, %v, want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParseBirthDate(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		field      *models.DocumentField
+		monthFirst bool
+		want       time.Time
+		ok         bool
+	}{
+		{"missing field", nil, false, time.Time{}, false},
+		{"four-digit year", &models.DocumentField{Value: "01/02/1965"}, true, date(1965, 1, 2), true},
+		{"two-digit year in the last century", &models.DocumentField{Value: "01/02/65"}, true, date(1965, 1, 2), true},
+		{"two-digit year below the time.Parse pivot", &models.DocumentField{Value: "01/02/45"}, true, date(1945, 1, 2), true},
+		{"two-digit year this century", &models.DocumentField{Value: "01/02/05"}, true, date(2005, 1, 2), true},
+		{"two-digit current year", &models.DocumentField{Value: "01/02/26"}, true, date(2026, 1, 2), true},
+		{"two-digit next year", &models.DocumentField{Value: "01/02/27"}, true, date(1927, 1, 2), true},
+		{"compact passport date", &models.DocumentField{Value: "02MAR65"}, false, date(1965, 3, 2), true},
+		{"two-digit month name", &models.DocumentField{Value: "02 Jan 50"}, false, date(1950, 1, 2), true},
+		{"normalized value", &models.DocumentField{Value: "01/02/65", Normalized: "1965-01-02T00:00:00"}, true, date(1965, 1, 2), true},
+		{"future normalized value", &models.DocumentField{Value: "01/02/65", Normalized: "2065-01-02T00:00:00"}, true, date(1965, 1, 2), true},
+		{"leap day", &models.DocumentField{Value: "29.02.00"}, false, date(2000, 2, 29), true},
+		{"unreadable", &models.DocumentField{Value: "unknown"}, false, time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseBirthDate(tt.field, tt.monthFirst, now)
+			if ok != tt.ok || !got.Equal(tt.want) {
+				t.Errorf("parseBirthDate() = %v, %v, want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestExpandTwoDigitYear(t *testing.T) {
+	tests := []struct {
+		year, want int
+	}{
+		{0, 2000},
+		{30, 2030},
+		{68, 2068},
+		{69, 1969},
+		{99, 1999},
+	}
+
+	for _, tt := range tests {
+		if got := expandTwoDigitYear(tt.year); got != tt.want {
+			t.Errorf("expandTwoDigitYear(%d) = %d, want %d", tt.year, got, tt.want)
+		}
+	}
+}
+
+func TestExpandBirthYear(t *testing.T) {
+	tests := []struct {
+		year int
+		now  time.Time
+		want int
+	}{
+		{65, date(2026, 10, 16), 1965},
+		{26, date(2026, 10, 16), 2026},
+		{27, date(2026, 10, 16), 1927},
+		{0, date(2026, 10, 16), 2000},
+		{99, date(2000, 1, 1), 1999},
+		{0, date(2000, 1, 1), 2000},
+		{1, date(2000, 1, 1), 1901},
+	}
+
+	for _, tt := range tests {
+		if got := expandBirthYear(tt.year, tt.now); got != tt.want {
+			t.Errorf("expandBirthYear(%d, %v) = %d, want %d", tt.year, tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestAgeOn(t *testing.T) {
+	tests := []struct {
+		name     string
+		dob, now time.Time
+		want     int
+	}{
+		{"birthday today", date(2000, 10, 16), date(2018, 10, 16), 18},
+		{"day before birthday", date(2000, 10, 16), date(2018, 10, 15), 17},
+		{"month before birthday", date(2000, 10, 16), date(2018, 9, 30), 17},
+		{"after birthday", date(2000, 10, 16), date(2018, 12, 1), 18},
+		{"time of day ignored", date(2000, 10, 16), time.Date(2018, 10, 16, 0, 0, 1, 0, time.UTC), 18},
+		{"leap day birthday in common year", date(2004, 2, 29), date(2022, 2, 28), 17},
+		{"leap day birthday after Feb 28", date(2004, 2, 29), date(2022, 3, 1), 18},
+		{"born today", date(2026, 10, 16), date(2026, 10, 16), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ageOn(tt.dob, tt.now); got != tt.want {
+				t.Errorf("ageOn(%v, %v) = %d, want %d", tt.dob, tt.now, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/models"
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/repo"
+)
+
+// ErrorKind classifies why a verification could not be completed
+type ErrorKind string
+
+const (
+	// KindValidation means the submitted data was invalid
+	KindValidation ErrorKind = "validation"
+	// KindQualityRejected means the images could not be evaluated, e.g. the
+	// selfie was too dark or no document was found
+	KindQualityRejected ErrorKind = "quality_rejected"
+	// KindDuplicate means the identity has already been verified
+	KindDuplicate ErrorKind = "duplicate"
+	// KindLocked means the identity failed too many attempts and may not
+	// retry yet
+	KindLocked ErrorKind = "locked"
+	// KindThrottled means an upstream provider rate limited us
+	KindThrottled ErrorKind = "throttled"
+	// KindUnavailable means an upstream provider or the attempt store could
+	// not be reached
+	KindUnavailable ErrorKind = "unavailable"
+	// KindUpstream covers any other upstream provider failure
+	KindUpstream ErrorKind = "upstream"
+)
+
+// Error is returned by KYCService for every failure that prevents a
+// verification decision. Message is safe to show to clients; Err holds the
+// underlying cause for logging.
+type Error struct {
+	Kind    ErrorKind
+	Reason  models.ReasonCode
+	Message string
+	// Result holds the checks run so far, when any were run
+	Result *models.VerificationResult
+	Err    error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return string(e.Kind) + ": " + e.Message + ": " + e.Err.Error()
+	}
+	return string(e.Kind) + ": " + e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// rejectionKinds maps the checks whose failure means the submission could
+// not be evaluated to the error kind reported for them. Other failed checks
+// are verification decisions and produce an unverified result instead.
+var rejectionKinds = map[models.CheckName]ErrorKind{
+	models.CheckInputValidation:  KindValidation,
+	models.CheckDuplicate:        KindDuplicate,
+	models.CheckAttemptLimit:     KindLocked,
+	models.CheckDocumentAnalysis: KindQualityRejected,
+	models.CheckFaceCount:        KindQualityRejected,
+	models.CheckFaceConfidence:   KindQualityRejected,
+	models.CheckBrightness:       KindQualityRejected,
+	models.CheckSharpness:        KindQualityRejected,
+}
+
+// upstreamError converts a repository failure into an Error with a sanitized
+// message
+func upstreamError(err error) *Error {
+	switch {
+	case errors.Is(err, repo.ErrThrottled):
+		return &Error{Kind: KindThrottled, Message: "Verification provider is busy, please retry later", Err: err}
+	case errors.Is(err, repo.ErrUnavailable):
+		return &Error{Kind: KindUnavailable, Message: "Verification service is temporarily unavailable", Err: err}
+	case errors.Is(err, repo.ErrInvalidInput):
+		return &Error{
+			Kind:    KindQualityRejected,
+			Reason:  models.ReasonInvalidInput,
+			Message: "The submitted image could not be processed",
+			Err:     err,
+		}
+	default:
+		return &Error{Kind: KindUpstream, Message: "Verification provider error", Err: err}
+	}
+}
+
+// storageError reports a verification whose attempt could not be recorded.
+// The decision is withheld, since an unrecorded success could be repeated and
+// an unrecorded failure would not count towards the attempt limit.
+func storageError(err error) *Error {
+	return &Error{Kind: KindUnavailable, Message: "Verification could not be recorded, please retry later", Err: err}
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/models"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// minNameMatchScore is the lowest fuzzy score at which a supplied name is
+// considered to match the name printed on the document
+const minNameMatchScore = 0.85
+
+// transliterations covers Latin letters that do not decompose into a base
+// letter plus combining marks under NFD
+var transliterations = strings.NewReplacer(
+	"ß", "ss", "æ", "ae", "œ", "oe", "ø", "o", "ł", "l",
+	"đ", "d", "ð", "d", "þ", "th", "ı", "i", "ħ", "h",
+)
+
+// umlautExpansions mirrors the German convention (also used in MRZ lines) of
+// writing ä, ö and ü as ae, oe and ue
+var umlautExpansions = strings.NewReplacer("ä", "ae", "ö", "oe", "ü", "ue")
+
+// nameTokens splits a name into lowercase tokens with apostrophes removed and
+// hyphens, commas and other separators treated as word boundaries. Tokens
+// made only of combining marks are dropped, as nothing is left of them once
+// folded.
+func nameTokens(name string) []string {
+	name = strings.ToLower(name)
+	name = strings.NewReplacer("'", "", "’", "", "`", "").Replace(name)
+	fields := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.Is(unicode.Mn, r)
+	})
+
+	tokens := fields[:0]
+	for _, token := range fields {
+		if stripDiacritics(token) != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// foldToken returns the ASCII spellings a token may be written as: with
+// diacritics stripped, and with German umlauts expanded
+func foldToken(token string) []string {
+	plain := stripDiacritics(transliterations.Replace(token))
+	expanded := stripDiacritics(transliterations.Replace(umlautExpansions.Replace(norm.NFC.String(token))))
+	if plain == expanded {
+		return []string{plain}
+	}
+	return []string{plain, expanded}
+}
+
+func stripDiacritics(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	result, _, err := transform.String(t, s)
+	if err != nil {
+		return s
+	}
+	return result
+}
+
+// nameMatchScore compares two full names and returns a score between 0 and 1.
+// Token order is ignored, and extra tokens on the longer side (such as middle
+// names) are not penalized as long as at least two tokens match. Single
+// letters match any token with the same initial.
+func nameMatchScore(supplied, extracted string) float64 {
+	a := nameTokens(supplied)
+	b := nameTokens(extracted)
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	shorter, longer := a, b
+	if len(shorter) > len(longer) {
+		shorter, longer = longer, shorter
+	}
+
+	used := make([]bool, len(longer))
+	var total float64
+	for _, token := range shorter {
+		best, bestIdx := 0.0, -1
+		for i, candidate := range longer {
+			if used[i] {
+				continue
+			}
+			if score := tokenSimilarity(token, candidate); score > best {
+				best, bestIdx = score, i
+			}
+		}
+		if bestIdx >= 0 {
+			used[bestIdx] = true
+		}
+		total += best
+	}
+
+	score := total / float64(len(shorter))
+	// A lone given name or surname, or nothing but initials, is not enough to
+	// identify someone
+	if (len(shorter) == 1 && len(longer) > 1) || onlyInitials(shorter) {
+		score /= 2
+	}
+
+	return score
+}
+
+func onlyInitials(tokens []string) bool {
+	for _, token := range tokens {
+		if len([]rune(token)) > 1 {
+			return false
+		}
+	}
+	return true
+}
+
+func tokenSimilarity(a, b string) float64 {
+	var best float64
+	for _, x := range foldToken(a) {
+		for _, y := range foldToken(b) {
+			if x == "" || y == "" {
+				continue
+			}
+			if len([]rune(x)) == 1 || len([]rune(y)) == 1 {
+				if []rune(x)[0] == []rune(y)[0] {
+					best = max(best, 1)
+				}
+				continue
+			}
+			best = max(best, levenshteinRatio(x, y))
+		}
+	}
+	return best
+}
+
+// levenshteinRatio is 1 minus the edit distance normalized by the longer string
+func levenshteinRatio(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 && len(rb) == 0 {
+		return 1
+	}
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return 1 - float64(prev[len(rb)])/float64(max(len(ra), len(rb)))
+}
+
+// Field names reported in models.FieldMatch
+const (
+	matchFieldFullName    = "full_name"
+	matchFieldDateOfBirth = "date_of_birth"
+)
+
+var matchChecks = map[string]struct {
+	name   models.CheckName
+	reason models.ReasonCode
+}{
+	matchFieldFullName:    {models.CheckNameMatch, models.ReasonNameMismatch},
+	matchFieldDateOfBirth: {models.CheckDateOfBirthMatch, models.ReasonDateOfBirthMismatch},
+}
+
+// matchCheck converts a field match into its verification check
+func matchCheck(match models.FieldMatch) models.CheckResult {
+	check := matchChecks[match.Field]
+	threshold := 1.0
+	if match.Field == matchFieldFullName {
+		threshold = minNameMatchScore
+	}
+
+	if !match.Matched {
+		return failedCheck(check.name, check.reason,
+			fmt.Sprintf("Supplied %s does not match the ID document", match.Field), match.Score, threshold)
+	}
+	return passedCheck(check.name, match.Score, threshold)
+}
+
+// matchApplicantDetails compares the optional name and date of birth supplied
+// with the request against the ID document. Fields the applicant did not
+// supply are not reported.
+func (s *kycService) matchApplicantDetails(document *models.IdentityDocument, req models.KYCRequest, now time.Time) []models.FieldMatch {
+	var matches []models.FieldMatch
+
+	if req.FullName != "" {
+		extracted := strings.Join(nonEmpty(
+			document.FirstName.Text(),
+			document.MiddleName.Text(),
+			document.LastName.Text(),
+		), " ")
+		score := nameMatchScore(req.FullName, extracted)
+		matches = append(matches, models.FieldMatch{
+			Field:     matchFieldFullName,
+			Supplied:  req.FullName,
+			Extracted: extracted,
+			Score:     score,
+			Matched:   score >= minNameMatchScore,
+		})
+	}
+
+	if req.DateOfBirth != "" {
+		match := models.FieldMatch{
+			Field:     matchFieldDateOfBirth,
+			Supplied:  req.DateOfBirth,
+			Extracted: document.DateOfBirth.Text(),
+		}
+		supplied, _ := time.Parse(time.DateOnly, req.DateOfBirth)
+		if dob, ok := parseBirthDate(document.DateOfBirth, document.IssuingState != nil, now); ok {
+			match.Extracted = dob.Format(time.DateOnly)
+			if dob.Equal(supplied) {
+				match.Score = 1
+				match.Matched = true
+			}
+		}
+		matches = append(matches, match)
+	}
+
+	for _, match := range matches {
+		s.logger.WithFields(map[string]interface{}{
+			"field":   match.Field,
+			"score":   match.Score,
+			"matched": match.Matched,
+		}).Info("Applicant details compared")
+	}
+
+	return matches
+}
+
+func nonEmpty(values ...string) []string {
+	var result []string
+	for _, v := range values {
+		if v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
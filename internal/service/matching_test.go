@@ -0,0 +1,106 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNameTokens(t *testing.T) {
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"John Smith", []string{"john", "smith"}},
+		{"SMITH, JOHN", []string{"smith", "john"}},
+		{"Mary-Jane O'Neil", []string{"mary", "jane", "oneil"}},
+		{"José  Álvarez", []string{"josé", "álvarez"}},
+		{"́ John Smith", []string{"john", "smith"}},
+		{"́̈", nil},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		got := nameTokens(tt.name)
+		if len(got) == 0 && len(tt.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("nameTokens(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNameMatchScore(t *testing.T) {
+	tests := []struct {
+		supplied  string
+		extracted string
+		matched   bool
+	}{
+		{"John Smith", "JOHN SMITH", true},
+		{"Smith John", "JOHN SMITH", true},
+		{"John Smith", "JOHN QUINCY SMITH", true},
+		{"John Q Smith", "JOHN QUINCY SMITH", true},
+		{"Jon Smith", "JOHN SMITH", true},
+		{"Jonathan Smyth", "JOHN SMITH", false},
+		{"José Álvarez", "JOSE ALVAREZ", true},
+		{"Jürgen Müller", "JUERGEN MUELLER", true},
+		{"Straße", "STRASSE", true},
+		{"John", "JOHN SMITH", false},
+		{"J S", "JOHN SMITH", false},
+		{"Jane Doe", "JOHN SMITH", false},
+		{"́ John Smith", "JOHN Q SMITH", true},
+		{"́ J", "JOHN Q SMITH", false},
+		{"́", "JOHN SMITH", false},
+		{"", "JOHN SMITH", false},
+		{"John Smith", "", false},
+	}
+
+	for _, tt := range tests {
+		score := nameMatchScore(tt.supplied, tt.extracted)
+		if score < 0 || score > 1 {
+			t.Errorf("nameMatchScore(%q, %q) = %v, want a score between 0 and 1", tt.supplied, tt.extracted, score)
+		}
+		if matched := score >= minNameMatchScore; matched != tt.matched {
+			t.Errorf("nameMatchScore(%q, %q) = %v, matched %v, want %v", tt.supplied, tt.extracted, score, matched, tt.matched)
+		}
+	}
+}
+
+func TestTokenSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"john", "john", 1},
+		{"j", "john", 1},
+		{"john", "j", 1},
+		{"q", "john", 0},
+		{"́", "j", 0},
+		{"j", "́", 0},
+	}
+
+	for _, tt := range tests {
+		if got := tokenSimilarity(tt.a, tt.b); got != tt.want {
+			t.Errorf("tokenSimilarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestLevenshteinRatio(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"", "", 1},
+		{"smith", "smith", 1},
+		{"smith", "smyth", 0.8},
+		{"abc", "", 0},
+		{"kitten", "sitting", 1 - 3.0/7},
+	}
+
+	for _, tt := range tests {
+		if got := levenshteinRatio(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshteinRatio(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
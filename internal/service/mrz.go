@@ -0,0 +1,117 @@
+package service
+
+import (
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/models"
+	"github.com/SwanHtetAungPhyo/kyc-api/pkg/config"
+	"github.com/SwanHtetAungPhyo/kyc-api/pkg/mrz"
+)
+
+// checkMRZ validates the document's MRZ, stores the outcome on the result and
+// flags the result as suspicious when the MRZ fails. MRZ problems produce a
+// warning rather than failing the verification.
+func (s *kycService) checkMRZ(result *models.VerificationResult, now time.Time) models.CheckResult {
+	if result.Document.MRZCode == nil {
+		return skippedCheck(models.CheckMRZ, "Document has no machine readable zone")
+	}
+
+	result.MRZ = s.validateMRZ(result.Document, now)
+	result.Suspicious = result.MRZ.Suspicious()
+	if result.Suspicious {
+		return models.CheckResult{
+			Name:    models.CheckMRZ,
+			Status:  models.CheckWarning,
+			Reason:  models.ReasonMRZSuspicious,
+			Message: "Machine readable zone is invalid or does not match the document",
+		}
+	}
+
+	return passedCheck(models.CheckMRZ, nil, nil)
+}
+
+// validateMRZ validates the check digits of the document's MRZ and compares
+// the MRZ-encoded fields with those read from the visual zone. Fields missing
+// from the visual zone are not compared.
+func (s *kycService) validateMRZ(document *models.IdentityDocument, now time.Time) *models.MRZResult {
+	zone, err := mrz.Parse(document.MRZCode.Value)
+	if err != nil {
+		s.logger.WithError(err).Info("Unable to parse document MRZ")
+		return &models.MRZResult{}
+	}
+
+	result := &models.MRZResult{
+		Format:         string(zone.Format),
+		Parsed:         true,
+		ChecksumsValid: zone.Valid(),
+		FailedChecks:   zone.FailedChecks(),
+	}
+
+	if number := document.DocumentNumber.Text(); number != "" && alphanumeric(number) != zone.DocumentNumber {
+		result.Mismatches = append(result.Mismatches, mrz.FieldDocumentNumber)
+	}
+
+	monthFirst := document.IssuingState != nil
+	if dob, ok := parseBirthDate(document.DateOfBirth, monthFirst, now); ok {
+		if mrzDOB, err := zone.BirthDate(now); err != nil || !mrzDOB.Equal(startOfDay(dob)) {
+			result.Mismatches = append(result.Mismatches, mrz.FieldDateOfBirth)
+		}
+	}
+
+	if expiry, ok := parseDocumentDate(document.ExpirationDate, monthFirst); ok {
+		if mrzExpiry, err := zone.ExpiryDate(); err != nil || !mrzExpiry.Equal(startOfDay(expiry)) {
+			result.Mismatches = append(result.Mismatches, mrz.FieldExpiryDate)
+		}
+	}
+
+	visualName := strings.Join(nonEmpty(
+		document.FirstName.Text(),
+		document.MiddleName.Text(),
+		document.LastName.Text(),
+	), " ")
+	if visualName != "" && nameMatchScore(zone.GivenNames+" "+zone.Surname, visualName) < minNameMatchScore {
+		result.Mismatches = append(result.Mismatches, mrz.FieldName)
+	}
+
+	if result.Suspicious() {
+		s.logger.WithFields(map[string]interface{}{
+			"format":        result.Format,
+			"failed_checks": result.FailedChecks,
+			"mismatches":    result.Mismatches,
+		}).Info("Document MRZ is suspicious")
+	}
+
+	return result
+}
+
+// alphanumeric upper-cases s and drops everything but letters and digits so
+// printed document numbers can be compared with their MRZ encoding
+func alphanumeric(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// issuingJurisdiction returns the code minimum age overrides are looked up
+// by: the US state of driver's licenses and state IDs, or else the ICAO
+// issuing state in the MRZ of passports and other travel documents. It is
+// empty when the document shows neither.
+func issuingJurisdiction(document *models.IdentityDocument) string {
+	if state := document.IssuingState.Text(); state != "" {
+		return config.JurisdictionCode(state)
+	}
+	if document.MRZCode == nil {
+		return ""
+	}
+	zone, err := mrz.Parse(document.MRZCode.Value)
+	if err != nil {
+		return ""
+	}
+	return config.JurisdictionCode(zone.IssuingState)
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/models"
+)
+
+// specimenTD3 is the passport MRZ of the ICAO 9303 specimen
+const specimenTD3 = "P<UTOERIKSSON<<ANNA<MARIA<<<<<<<<<<<<<<<<<<<\nL898902C36UTO7408122F1204159ZE184226B<<<<<10"
+
+func TestIssuingJurisdiction(t *testing.T) {
+	tests := []struct {
+		name     string
+		document *models.IdentityDocument
+		want     string
+	}{
+		{"state code", &models.IdentityDocument{IssuingState: &models.DocumentField{Value: "MS"}}, "MS"},
+		{"state name", &models.IdentityDocument{IssuingState: &models.DocumentField{Value: "MISSISSIPPI"}}, "MS"},
+		{"state preferred over MRZ", &models.IdentityDocument{
+			IssuingState: &models.DocumentField{Value: "Alabama"},
+			MRZCode:      &models.DocumentField{Value: specimenTD3},
+		}, "AL"},
+		{"passport MRZ", &models.IdentityDocument{MRZCode: &models.DocumentField{Value: specimenTD3}}, "UTO"},
+		{"unreadable MRZ", &models.IdentityDocument{MRZCode: &models.DocumentField{Value: "not an MRZ"}}, ""},
+		{"neither", &models.IdentityDocument{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := issuingJurisdiction(tt.document); got != tt.want {
+				t.Errorf("issuingJurisdiction() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/models"
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/repo"
+)
+
+// saveRecord completes the verification record from the outcome of a
+// verification and stores it. The outcome stands even if the record cannot
+// be stored.
+func (s *kycService) saveRecord(ctx context.Context, record *models.VerificationRecord, result *models.VerificationResult, err error) {
+	record.CompletedAt = time.Now()
+	record.Profile = result.Profile
+	record.Similarity = result.Similarity
+	record.Suspicious = result.Suspicious
+	record.Checks = result.Checks
+
+	var svcErr *Error
+	switch {
+	case err == nil && result.Verified:
+		record.Status = models.VerificationVerified
+		record.Message = result.Message
+	case err == nil:
+		record.Status = models.VerificationUnverified
+		record.Reason = result.Reason
+		record.Message = result.Message
+	case errors.As(err, &svcErr):
+		record.Status = models.VerificationError
+		if svcErr.Kind == KindValidation || svcErr.Kind == KindQualityRejected ||
+			svcErr.Kind == KindDuplicate || svcErr.Kind == KindLocked {
+			record.Status = models.VerificationRejected
+		}
+		record.Reason = svcErr.Reason
+		record.Message = svcErr.Message
+	default:
+		record.Status = models.VerificationError
+		record.Message = "Internal server error"
+	}
+
+	log := s.logger.WithField("verification_id", record.ID)
+	if result.Document != nil {
+		document, err := s.recordDocument(result.Document)
+		if err != nil {
+			log.WithError(err).Error("Failed to seal document fields, recording verification without them")
+		}
+		record.Document = document
+	}
+
+	if err := s.verifications.SaveVerification(context.WithoutCancel(ctx), record); err != nil {
+		log.WithError(err).Error("Failed to save verification record")
+	}
+}
+
+// recordDocument keeps the descriptive fields of the document in the clear
+// and seals the fields that identify its holder
+func (s *kycService) recordDocument(document *models.IdentityDocument) (*models.RecordedDocument, error) {
+	sealed, err := json.Marshal(models.SealedDocumentFields{
+		FirstName:      fieldValue(document.FirstName),
+		MiddleName:     fieldValue(document.MiddleName),
+		LastName:       fieldValue(document.LastName),
+		DateOfBirth:    fieldValue(document.DateOfBirth),
+		DocumentNumber: fieldValue(document.DocumentNumber),
+		Address:        fieldValue(document.Address),
+		MRZCode:        fieldValue(document.MRZCode),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal document fields: %w", err)
+	}
+
+	recorded := &models.RecordedDocument{
+		DocumentType:   fieldValue(document.DocumentType),
+		IssuingState:   fieldValue(document.IssuingState),
+		DateOfIssue:    fieldValue(document.DateOfIssue),
+		ExpirationDate: fieldValue(document.ExpirationDate),
+	}
+	// Hash the number as printed regardless of spacing and case, so it
+	// matches across submissions
+	if number := strings.ToUpper(strings.Join(strings.Fields(fieldValue(document.DocumentNumber)), "")); number != "" {
+		recorded.DocumentNumberHash = s.sealer.Hash(number)
+	}
+	recorded.Sealed, err = s.sealer.Seal(sealed)
+	return recorded, err
+}
+
+func fieldValue(field *models.DocumentField) string {
+	if field == nil {
+		return ""
+	}
+	return field.Value
+}
+
+func (s *kycService) GetVerification(ctx context.Context, tenantID, id string) (*models.VerificationView, error) {
+	record, err := s.verifications.GetVerification(ctx, tenantID, id)
+	if err != nil || record == nil {
+		return nil, err
+	}
+	view := s.view(*record)
+	return &view, nil
+}
+
+func (s *kycService) ListVerifications(ctx context.Context, query repo.VerificationQuery) ([]models.VerificationView, string, error) {
+	page, err := s.verifications.ListVerifications(ctx, query)
+	if err != nil {
+		return nil, "", err
+	}
+
+	views := make([]models.VerificationView, 0, len(page.Records))
+	for _, record := range page.Records {
+		views = append(views, s.view(record))
+	}
+	return views, page.NextCursor, nil
+}
+
+// view opens the sealed document fields of a record. Records sealed under
+// another key are returned without them.
+func (s *kycService) view(record models.VerificationRecord) models.VerificationView {
+	view := models.VerificationView{VerificationRecord: record}
+	if record.Document == nil || record.Document.Sealed == "" {
+		return view
+	}
+
+	document := *record.Document
+	document.Sealed = ""
+	view.Document = &document
+
+	plaintext, err := s.sealer.Open(record.Document.Sealed)
+	if err == nil {
+		view.Holder = &models.SealedDocumentFields{}
+		err = json.Unmarshal(plaintext, view.Holder)
+	}
+	if err != nil {
+		view.Holder = nil
+		s.logger.WithError(err).WithField("verification_id", record.ID).Error("Failed to open sealed document fields")
+	}
+	return view
+}
@@ -4,183 +4,453 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/SwanHtetAungPhyo/kyc-api/internal/models"
 	"github.com/SwanHtetAungPhyo/kyc-api/internal/repo"
+	"github.com/SwanHtetAungPhyo/kyc-api/pkg/config"
 	"github.com/SwanHtetAungPhyo/kyc-api/pkg/logger"
-	"github.com/aws/aws-sdk-go-v2/service/rekognition"
+	"github.com/SwanHtetAungPhyo/kyc-api/pkg/seal"
+	"github.com/google/uuid"
 )
 
 type KYCService interface {
-	VerifyKYC(ctx context.Context, idBlob, selfieBlob []byte, email string) (*models.VerificationResult, error)
-	CheckIfProceed(ctx context.Context, email string) (bool, error)
+	VerifyKYC(ctx context.Context, idBlob, selfieBlob []byte, req models.KYCRequest) (*models.VerificationResult, error)
+	CheckIfProceed(ctx context.Context, tenantID, email string) (bool, error)
+	// GetVerification returns the tenant's verification record with the ID,
+	// or nil if the tenant has none
+	GetVerification(ctx context.Context, tenantID, id string) (*models.VerificationView, error)
+	// ListVerifications returns a page of the tenant's verification records
+	// and the cursor of the next page, if any
+	ListVerifications(ctx context.Context, query repo.VerificationQuery) ([]models.VerificationView, string, error)
 }
 
 type kycService struct {
-	awsRepo  repo.AWSRepository
-	logger   logger.Logger
-	criteria models.FaceValidationCriteria
+	documents     repo.DocumentAnalyzer
+	faces         repo.FaceDetector
+	comparer      repo.FaceComparer
+	attempts      repo.AttemptStore
+	verifications repo.VerificationStore
+	sealer        *seal.Sealer
+	logger        logger.Logger
+	settings      *config.Live
 }
 
-func NewKYCService(awsRepo repo.AWSRepository, log logger.Logger) KYCService {
+// NewKYCService creates the verification service. Thresholds and age limits
+// are read from settings on every verification, so reloads apply to the next
+// request. sealer protects the document fields of verification records.
+func NewKYCService(providers repo.Providers, log logger.Logger, settings *config.Live, sealer *seal.Sealer) KYCService {
 	return &kycService{
-		awsRepo:  awsRepo,
-		logger:   log,
-		criteria: models.DefaultFaceValidationCriteria(),
+		documents:     providers.Documents,
+		faces:         providers.Faces,
+		comparer:      providers.Comparer,
+		attempts:      providers.Attempts,
+		verifications: providers.Verifications,
+		sealer:        sealer,
+		logger:        log,
+		settings:      settings,
 	}
 }
 
-func (s *kycService) VerifyKYC(ctx context.Context, idBlob, selfieBlob []byte, email string) (*models.VerificationResult, error) {
-	s.logger.WithField("email", email).Info("Starting KYC verification")
+// VerifyKYC runs the verification and records its outcome, whatever it is
+func (s *kycService) VerifyKYC(ctx context.Context, idBlob, selfieBlob []byte, req models.KYCRequest) (*models.VerificationResult, error) {
+	record := &models.VerificationRecord{
+		ID:        uuid.NewString(),
+		TenantID:  req.TenantID,
+		Email:     req.Email,
+		StartedAt: time.Now(),
+		SourceIP:  req.SourceIP,
+		UserAgent: req.UserAgent,
+	}
 
-	if err := s.validateInput(idBlob, selfieBlob); err != nil {
-		return nil, err
+	result := &models.VerificationResult{VerificationID: record.ID}
+	verified, err := s.verify(ctx, result, idBlob, selfieBlob, req)
+	s.saveRecord(ctx, record, result, err)
+	return verified, err
+}
+
+// verify runs the checks, filling in result as they complete. On failure
+// result still holds the checks run so far.
+func (s *kycService) verify(ctx context.Context, result *models.VerificationResult, idBlob, selfieBlob []byte, req models.KYCRequest) (*models.VerificationResult, error) {
+	attemptKey := repo.AttemptKey(req.TenantID, req.Email)
+	s.logger.WithFields(map[string]interface{}{
+		"verification_id": result.VerificationID,
+		"tenant_id":       req.TenantID,
+		"profile":         req.Profile,
+	}).Info("Starting KYC verification")
+	s.logger.WithFields(map[string]interface{}{
+		"verification_id": result.VerificationID,
+		"email":           req.Email,
+	}).Debug("KYC verification applicant")
+
+	current := s.settings.Current()
+	settings := current.KYC
+	policy, err := settings.Policy(req.Profile)
+	if err != nil {
+		return s.fail(result, failedCheck(models.CheckInputValidation, models.ReasonInvalidInput, err.Error(), nil, nil))
+	}
+	result.Profile = policy.Profile
+
+	if err := s.validateInput(idBlob, selfieBlob, req); err != nil {
+		return s.fail(result, failedCheck(models.CheckInputValidation, models.ReasonInvalidInput, err.Error(), nil, nil))
 	}
+	result.Checks = append(result.Checks, passedCheck(models.CheckInputValidation, nil, nil))
 
-	if err := s.analyzeIDDocument(ctx, idBlob); err != nil {
+	// Hold the identity before any provider call, so concurrent submissions
+	// for it are turned away instead of being verified twice
+	reservation := req.ReservationID
+	if reservation == "" {
+		reservation = result.VerificationID
+	}
+	err = s.attempts.Reserve(ctx, attemptKey, reservation, current.Attempts.Lease)
+	var conflict *repo.ConflictError
+	switch {
+	case errors.As(err, &conflict) && conflict.InProgress:
+		return s.fail(result, failedCheck(models.CheckDuplicate, models.ReasonInProgress,
+			"KYC with this email is already in progress", nil, nil))
+	case errors.As(err, &conflict):
+		return s.fail(result, failedCheck(models.CheckDuplicate, models.ReasonAlreadyVerified,
+			"KYC with this email is already done successfully", nil, nil))
+	case err != nil:
+		s.logger.WithError(err).Error("Failed to check email status")
+		return nil, upstreamError(fmt.Errorf("failed to check email status: %w", err))
+	}
+	defer s.release(ctx, attemptKey, reservation)
+	result.Checks = append(result.Checks, passedCheck(models.CheckDuplicate, nil, nil))
+
+	attempts, err := s.attempts.GetAttempts(ctx, attemptKey)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to check email status")
+		return nil, upstreamError(fmt.Errorf("failed to check email status: %w", err))
+	}
+
+	limitCheck := checkAttemptLimit(attempts, settings.Retry, time.Now())
+	if limitCheck.Status == models.CheckFailed {
+		s.logger.WithField("verification_id", result.VerificationID).Info("Rejected attempt of locked identity")
+		return s.fail(result, limitCheck)
+	}
+	result.Checks = append(result.Checks, limitCheck)
+
+	document, err := s.analyzeIDDocument(ctx, idBlob)
+	if err != nil {
 		s.logger.WithError(err).Error("ID document analysis failed")
-		return nil, fmt.Errorf("ID analysis failed: %w", err)
+		return nil, upstreamError(fmt.Errorf("ID analysis failed: %w", err))
+	}
+	if document == nil {
+		return s.reject(ctx, attemptKey, result, failedCheck(models.CheckDocumentAnalysis, models.ReasonDocumentUnreadable,
+			"No identity document detected in image", nil, nil))
+	}
+	result.Document = document
+	result.Checks = append(result.Checks, passedCheck(models.CheckDocumentAnalysis, nil, nil))
+
+	now := time.Now()
+	documentChecks := []models.CheckResult{
+		runCheck(policy, models.CheckDocumentValidity, func() models.CheckResult {
+			return s.checkDocumentValidity(document, now)
+		}),
+		runCheck(policy, models.CheckMRZ, func() models.CheckResult {
+			return s.checkMRZ(result, now)
+		}),
+		runCheck(policy, models.CheckMinimumAge, func() models.CheckResult {
+			return s.checkMinimumAge(document, now, policy)
+		}),
+	}
+	result.Matches = s.matchApplicantDetails(document, req, now)
+	for _, match := range result.Matches {
+		documentChecks = append(documentChecks, runCheck(policy, matchChecks[match.Field].name, func() models.CheckResult {
+			return matchCheck(match)
+		}))
+	}
+
+	for _, check := range documentChecks {
+		if check.Status == models.CheckFailed {
+			return s.reject(ctx, attemptKey, result, check)
+		}
+		result.Checks = append(result.Checks, check)
 	}
 
-	_, err := s.detectAndValidateFaces(ctx, selfieBlob)
+	faceChecks, err := s.detectAndValidateFaces(ctx, selfieBlob, policy.Face)
 	if err != nil {
-		s.logger.WithError(err).Error("Face detection/validation failed")
-		return nil, fmt.Errorf("face validation failed: %w", err)
+		s.logger.WithError(err).Error("Face detection failed")
+		return nil, upstreamError(fmt.Errorf("face detection failed: %w", err))
+	}
+	for _, check := range faceChecks {
+		if check.Status == models.CheckFailed {
+			return s.reject(ctx, attemptKey, result, check)
+		}
+		result.Checks = append(result.Checks, check)
 	}
 
-	similarity, err := s.compareFaces(ctx, idBlob, selfieBlob)
+	similarity, err := s.compareFaces(ctx, idBlob, selfieBlob, policy.Face.MinSimilarity)
 	if err != nil {
 		s.logger.WithError(err).Error("Face comparison failed")
-		return nil, fmt.Errorf("face comparison failed: %w", err)
+		return nil, upstreamError(fmt.Errorf("face comparison failed: %w", err))
 	}
+	result.Similarity = similarity
 
-	verified := similarity >= s.criteria.MinSimilarity
-	message := s.generateVerificationMessage(verified, similarity)
+	verified := similarity >= policy.Face.MinSimilarity
+	message := s.generateVerificationMessage(verified, similarity, policy.Face.MinSimilarity)
+	if !verified {
+		return s.reject(ctx, attemptKey, result, failedCheck(models.CheckSimilarity, models.ReasonFaceMismatch,
+			message, similarity, policy.Face.MinSimilarity))
+	}
+	result.Checks = append(result.Checks, passedCheck(models.CheckSimilarity, similarity, policy.Face.MinSimilarity))
 
-	if err := s.awsRepo.RecordAttempt(ctx, email, verified); err != nil {
+	if err := s.attempts.RecordAttempt(ctx, attemptKey, true); err != nil {
+		if errors.As(err, &conflict) {
+			return s.fail(result, failedCheck(models.CheckDuplicate, models.ReasonAlreadyVerified,
+				"KYC with this email was completed by another submission", nil, nil))
+		}
 		s.logger.WithError(err).Error("Failed to record KYC attempt")
+		return nil, storageError(fmt.Errorf("failed to record attempt: %w", err))
 	}
 
-	result := &models.VerificationResult{
-		Verified:   verified,
-		Similarity: similarity,
-		Message:    message,
-	}
+	result.Verified = true
+	result.Message = message
+	result.Checks = completeChecks(result.Checks)
 
 	s.logger.WithFields(map[string]interface{}{
-		"email":      email,
-		"verified":   verified,
-		"similarity": similarity,
+		"verification_id": result.VerificationID,
+		"tenant_id":       req.TenantID,
+		"profile":         policy.Profile,
+		"verified":        verified,
+		"similarity":      similarity,
+		"suspicious":      result.Suspicious,
 	}).Info("KYC verification completed")
 
 	return result, nil
 }
 
-func (s *kycService) CheckIfProceed(ctx context.Context, email string) (bool, error) {
-	return s.awsRepo.CheckIfProceed(ctx, email)
+// fail marks the result as failed by the given check without recording an
+// attempt. It is used directly for checks that run before any document is
+// analyzed. Checks listed in rejectionKinds are returned as an *Error
+// carrying the result; any other failure is an unverified result.
+func (s *kycService) fail(result *models.VerificationResult, check models.CheckResult) (*models.VerificationResult, error) {
+	result.Verified = false
+	result.Reason = check.Reason
+	result.Message = check.Message
+	result.Checks = completeChecks(append(result.Checks, check))
+
+	if kind, ok := rejectionKinds[check.Name]; ok {
+		return nil, &Error{
+			Kind:    kind,
+			Reason:  check.Reason,
+			Message: check.Message,
+			Result:  result,
+		}
+	}
+	return result, nil
+}
+
+// reject marks the result as failed by the given check and records the
+// failed attempt. A decision that cannot be recorded is not returned.
+func (s *kycService) reject(ctx context.Context, attemptKey string, result *models.VerificationResult, check models.CheckResult) (*models.VerificationResult, error) {
+	s.logger.WithFields(map[string]interface{}{
+		"verification_id": result.VerificationID,
+		"check":           check.Name,
+		"reason":          check.Reason,
+	}).Info("KYC verification rejected")
+
+	if err := s.attempts.RecordAttempt(ctx, attemptKey, false); err != nil {
+		s.logger.WithError(err).Error("Failed to record KYC attempt")
+		return nil, storageError(fmt.Errorf("failed to record attempt: %w", err))
+	}
+
+	return s.fail(result, check)
+}
+
+// release frees the identity once the verification has finished. A
+// reservation that cannot be released expires with its lease.
+func (s *kycService) release(ctx context.Context, attemptKey, reservation string) {
+	if err := s.attempts.Release(context.WithoutCancel(ctx), attemptKey, reservation); err != nil {
+		s.logger.WithError(err).WithField("reservation", reservation).Error("Failed to release KYC reservation")
+	}
+}
+
+func (s *kycService) CheckIfProceed(ctx context.Context, tenantID, email string) (bool, error) {
+	attempts, err := s.attempts.GetAttempts(ctx, repo.AttemptKey(tenantID, email))
+	if err != nil {
+		return false, err
+	}
+	return attempts != nil && attempts.Processed, nil
 }
 
-func (s *kycService) validateInput(idBlob, selfieBlob []byte) error {
+func (s *kycService) validateInput(idBlob, selfieBlob []byte, req models.KYCRequest) error {
 	if len(idBlob) == 0 {
 		return errors.New("ID image data is empty")
 	}
 	if len(selfieBlob) == 0 {
 		return errors.New("selfie image data is empty")
 	}
+	if req.DateOfBirth != "" {
+		if _, err := time.Parse(time.DateOnly, req.DateOfBirth); err != nil {
+			return errors.New("date_of_birth must be in YYYY-MM-DD format")
+		}
+	}
 	return nil
 }
 
-func (s *kycService) analyzeIDDocument(ctx context.Context, idBlob []byte) error {
-	_, err := s.awsRepo.AnalyzeID(ctx, idBlob)
+func (s *kycService) analyzeIDDocument(ctx context.Context, idBlob []byte) (*models.IdentityDocument, error) {
+	document, err := s.documents.AnalyzeID(ctx, idBlob)
 	if err != nil {
-		return fmt.Errorf("textract analysis failed: %w", err)
+		return nil, err
 	}
 
 	s.logger.Debug("ID document analysis completed successfully")
-	return nil
+	return document, nil
 }
 
-func (s *kycService) detectAndValidateFaces(ctx context.Context, selfieBlob []byte) (*rekognition.DetectFacesOutput, error) {
-	faces, err := s.awsRepo.DetectFaces(ctx, selfieBlob)
-	if err != nil {
-		return nil, err
+// checkDocumentValidity rejects documents that have expired or have an issue
+// date in the future. Dates that are missing or unreadable are not enforced.
+func (s *kycService) checkDocumentValidity(document *models.IdentityDocument, now time.Time) models.CheckResult {
+	today := startOfDay(now)
+	monthFirst := document.IssuingState != nil
+
+	var observed string
+	if expiry, ok := parseDocumentDate(document.ExpirationDate, monthFirst); ok {
+		observed = expiry.Format(time.DateOnly)
+		if startOfDay(expiry).Before(today) {
+			return failedCheck(models.CheckDocumentValidity, models.ReasonDocumentExpired,
+				fmt.Sprintf("ID document expired on %s", observed), observed, today.Format(time.DateOnly))
+		}
+	} else if document.ExpirationDate != nil {
+		s.logger.WithField("expiration_date", document.ExpirationDate.Value).Info("Unable to parse document expiration date")
 	}
 
-	if err := s.validateFaceQuality(faces); err != nil {
-		return nil, err
+	if issued, ok := parseDocumentDate(document.DateOfIssue, monthFirst); ok {
+		if startOfDay(issued).After(today) {
+			return failedCheck(models.CheckDocumentValidity, models.ReasonDocumentNotYetValid,
+				fmt.Sprintf("ID document is not valid until %s", issued.Format(time.DateOnly)),
+				issued.Format(time.DateOnly), today.Format(time.DateOnly))
+		}
+	} else if document.DateOfIssue != nil {
+		s.logger.WithField("date_of_issue", document.DateOfIssue.Value).Info("Unable to parse document issue date")
+	}
+
+	return passedCheck(models.CheckDocumentValidity, observed, nil)
+}
+
+// checkMinimumAge rejects applicants younger than the minimum age configured
+// for the document's issuing jurisdiction. When an age gate applies, a missing
+// or unreadable date of birth is also a rejection.
+func (s *kycService) checkMinimumAge(document *models.IdentityDocument, now time.Time, policy config.Policy) models.CheckResult {
+	minAge := policy.MinAge
+	if override, ok := policy.MinAgeByJurisdiction[issuingJurisdiction(document)]; ok {
+		minAge = override
+	}
+	if minAge <= 0 {
+		return skippedCheck(models.CheckMinimumAge, "No minimum age configured")
+	}
+
+	dob, ok := parseBirthDate(document.DateOfBirth, document.IssuingState != nil, now)
+	if !ok {
+		return failedCheck(models.CheckMinimumAge, models.ReasonDateOfBirthUnreadable,
+			"Unable to read date of birth from ID document", nil, minAge)
 	}
 
-	return faces, nil
+	age := ageOn(dob, now)
+	if age < minAge {
+		s.logger.WithFields(map[string]interface{}{
+			"age":     age,
+			"min_age": minAge,
+		}).Info("Applicant below minimum age")
+		return failedCheck(models.CheckMinimumAge, models.ReasonUnderage,
+			fmt.Sprintf("Applicant must be at least %d years old", minAge), age, minAge)
+	}
+
+	return passedCheck(models.CheckMinimumAge, age, minAge)
 }
 
-func (s *kycService) validateFaceQuality(faces *rekognition.DetectFacesOutput) error {
-	if len(faces.FaceDetails) != 1 {
-		s.logger.WithField("face_count", len(faces.FaceDetails)).Error("Invalid number of faces detected")
-		return fmt.Errorf("exactly one face should be detected, found %d", len(faces.FaceDetails))
+func (s *kycService) detectAndValidateFaces(ctx context.Context, selfieBlob []byte, criteria config.FaceCriteria) ([]models.CheckResult, error) {
+	faces, err := s.faces.DetectFaces(ctx, selfieBlob)
+	if err != nil {
+		return nil, err
 	}
 
-	face := faces.FaceDetails[0]
+	return s.validateFaceQuality(faces, criteria), nil
+}
 
-	if face.Confidence == nil || *face.Confidence < s.criteria.MinConfidence {
-		confidence := float32(0)
-		if face.Confidence != nil {
-			confidence = *face.Confidence
+// validateFaceQuality checks the selfie's face count, detection confidence,
+// brightness and sharpness. Checking stops at the first failure.
+func (s *kycService) validateFaceQuality(faces []models.FaceDetail, criteria config.FaceCriteria) []models.CheckResult {
+	count := len(faces)
+	if count != 1 {
+		s.logger.WithField("face_count", count).Error("Invalid number of faces detected")
+		reason := models.ReasonMultipleFaces
+		if count == 0 {
+			reason = models.ReasonNoFaceDetected
 		}
+		return []models.CheckResult{failedCheck(models.CheckFaceCount, reason,
+			fmt.Sprintf("exactly one face should be detected, found %d", count), count, 1)}
+	}
+	checks := []models.CheckResult{passedCheck(models.CheckFaceCount, count, 1)}
+
+	face := faces[0]
+
+	confidence := face.Confidence
+	if confidence < criteria.MinConfidence {
 		s.logger.WithField("confidence", confidence).Error("Low face detection confidence")
-		return fmt.Errorf("low face detection confidence: %.2f (required: %.2f)",
-			confidence, s.criteria.MinConfidence)
+		return append(checks, failedCheck(models.CheckFaceConfidence, models.ReasonLowFaceConfidence,
+			fmt.Sprintf("low face detection confidence: %.2f (required: %.2f)", confidence, criteria.MinConfidence),
+			confidence, criteria.MinConfidence))
 	}
+	checks = append(checks, passedCheck(models.CheckFaceConfidence, confidence, criteria.MinConfidence))
 
 	if face.Quality == nil {
-		return errors.New("missing face quality data")
+		return append(checks, failedCheck(models.CheckBrightness, models.ReasonFaceQualityMissing,
+			"incomplete face quality metrics", nil, criteria.MinBrightness))
 	}
 
-	if face.Quality.Brightness == nil || face.Quality.Sharpness == nil {
-		return errors.New("incomplete face quality metrics")
-	}
+	brightness := face.Quality.Brightness
+	sharpness := face.Quality.Sharpness
 
-	brightness := *face.Quality.Brightness
-	sharpness := *face.Quality.Sharpness
+	if brightness < criteria.MinBrightness {
+		s.logger.WithField("brightness", brightness).Error("Selfie too dark")
+		return append(checks, failedCheck(models.CheckBrightness, models.ReasonImageTooDark,
+			fmt.Sprintf("selfie is too dark (brightness: %.2f/%.2f)", brightness, criteria.MinBrightness),
+			brightness, criteria.MinBrightness))
+	}
+	checks = append(checks, passedCheck(models.CheckBrightness, brightness, criteria.MinBrightness))
 
-	if brightness < s.criteria.MinBrightness || sharpness < s.criteria.MinSharpness {
-		s.logger.WithFields(map[string]interface{}{
-			"brightness": brightness,
-			"sharpness":  sharpness,
-		}).Error("Poor image quality")
-		return fmt.Errorf("poor image quality (brightness: %.2f/%.2f, sharpness: %.2f/%.2f)",
-			brightness, s.criteria.MinBrightness, sharpness, s.criteria.MinSharpness)
+	if sharpness < criteria.MinSharpness {
+		s.logger.WithField("sharpness", sharpness).Error("Selfie too blurry")
+		return append(checks, failedCheck(models.CheckSharpness, models.ReasonImageTooBlurry,
+			fmt.Sprintf("selfie is too blurry (sharpness: %.2f/%.2f)", sharpness, criteria.MinSharpness),
+			sharpness, criteria.MinSharpness))
 	}
+	checks = append(checks, passedCheck(models.CheckSharpness, sharpness, criteria.MinSharpness))
 
 	s.logger.WithFields(map[string]interface{}{
-		"confidence": *face.Confidence,
+		"confidence": confidence,
 		"brightness": brightness,
 		"sharpness":  sharpness,
 	}).Info("Face validation passed")
 
-	return nil
+	return checks
 }
 
-func (s *kycService) compareFaces(ctx context.Context, idBlob, selfieBlob []byte) (float32, error) {
-	compareResult, err := s.awsRepo.CompareFaces(ctx, idBlob, selfieBlob, s.criteria.MinSimilarity)
+func (s *kycService) compareFaces(ctx context.Context, idBlob, selfieBlob []byte, minSimilarity float32) (float32, error) {
+	matches, err := s.comparer.CompareFaces(ctx, idBlob, selfieBlob, minSimilarity)
 	if err != nil {
 		return 0, err
 	}
 
-	if len(compareResult.FaceMatches) == 0 {
-		s.logger.Error("No face matches found")
-		return 0, errors.New("no face matches found")
+	// CompareFaces only returns matches above the similarity threshold
+	if len(matches) == 0 {
+		s.logger.Info("No face matches found")
+		return 0, nil
 	}
 
-	similarity := *compareResult.FaceMatches[0].Similarity
+	similarity := matches[0].Similarity
 	s.logger.WithField("similarity", similarity).Info("Face comparison completed")
 
 	return similarity, nil
 }
 
-func (s *kycService) generateVerificationMessage(verified bool, similarity float32) string {
+func (s *kycService) generateVerificationMessage(verified bool, similarity, minSimilarity float32) string {
 	if verified {
 		return fmt.Sprintf("KYC verification successful with %.2f%% similarity", similarity)
 	}
 	return fmt.Sprintf("KYC verification failed with %.2f%% similarity (required: %.2f%%)",
-		similarity, s.criteria.MinSimilarity)
+		similarity, minSimilarity)
 }
@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/models"
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/repo"
+	"github.com/SwanHtetAungPhyo/kyc-api/pkg/config"
+	"github.com/SwanHtetAungPhyo/kyc-api/pkg/logger"
+	"github.com/SwanHtetAungPhyo/kyc-api/pkg/seal"
+)
+
+// newTestService returns a service on the fake repository, which verifies
+// images without a scenario as a valid passport and a matching selfie
+func newTestService(t *testing.T, cfg *config.Config) (KYCService, repo.AWSRepository) {
+	t.Helper()
+
+	fake, err := repo.NewFakeRepository("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealer, err := seal.New("test secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewKYCService(repo.ProvidersFrom(fake), logger.NewLogger(), config.NewLive(cfg), sealer), fake
+}
+
+func TestVerifyKYC(t *testing.T) {
+	req := models.KYCRequest{Email: "jane@example.com", TenantID: "acme"}
+	key := repo.AttemptKey(req.TenantID, req.Email)
+
+	tests := []struct {
+		name   string
+		setup  func(ctx context.Context, fake repo.AWSRepository) error
+		id     string
+		selfie string
+
+		wantKind     ErrorKind
+		wantReason   models.ReasonCode
+		wantVerified bool
+		wantStatus   models.VerificationStatus
+		// wantFailures is the number of failed attempts recorded afterwards
+		wantFailures int
+	}{
+		{
+			name:         "success",
+			wantVerified: true,
+			wantStatus:   models.VerificationVerified,
+		},
+		{
+			name:         "reject on face mismatch",
+			selfie:       `{"similarity": 50}`,
+			wantReason:   models.ReasonFaceMismatch,
+			wantStatus:   models.VerificationUnverified,
+			wantFailures: 1,
+		},
+		{
+			name:         "reject unusable selfie",
+			selfie:       `{"faces": 0}`,
+			wantKind:     KindQualityRejected,
+			wantReason:   models.ReasonNoFaceDetected,
+			wantStatus:   models.VerificationRejected,
+			wantFailures: 1,
+		},
+		{
+			name:       "fail on provider outage",
+			id:         `{"errors": {"analyze_id": "unavailable"}}`,
+			wantKind:   KindUnavailable,
+			wantStatus: models.VerificationError,
+		},
+		{
+			name:       "fail on provider error",
+			selfie:     `{"errors": {"compare_faces": "boom"}}`,
+			wantKind:   KindUpstream,
+			wantStatus: models.VerificationError,
+		},
+		{
+			name: "conflict with a verified identity",
+			setup: func(ctx context.Context, fake repo.AWSRepository) error {
+				return fake.RecordAttempt(ctx, key, true)
+			},
+			wantKind:   KindDuplicate,
+			wantReason: models.ReasonAlreadyVerified,
+			wantStatus: models.VerificationRejected,
+		},
+		{
+			name: "conflict with a verification in progress",
+			setup: func(ctx context.Context, fake repo.AWSRepository) error {
+				return fake.Reserve(ctx, key, "other", time.Hour)
+			},
+			wantKind:   KindDuplicate,
+			wantReason: models.ReasonInProgress,
+			wantStatus: models.VerificationRejected,
+		},
+		{
+			name: "lockout after too many failures",
+			setup: func(ctx context.Context, fake repo.AWSRepository) error {
+				for range config.Default().KYC.Retry.MaxFailures {
+					if err := fake.RecordAttempt(ctx, key, false); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			wantKind:     KindLocked,
+			wantReason:   models.ReasonTooManyAttempts,
+			wantStatus:   models.VerificationRejected,
+			wantFailures: config.Default().KYC.Retry.MaxFailures,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			svc, fake := newTestService(t, config.Default())
+			if tt.setup != nil {
+				if err := tt.setup(ctx, fake); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			id, selfie := []byte("id"), []byte("selfie")
+			if tt.id != "" {
+				id = []byte(tt.id)
+			}
+			if tt.selfie != "" {
+				selfie = []byte(tt.selfie)
+			}
+
+			result, err := svc.VerifyKYC(ctx, id, selfie, req)
+			var svcErr *Error
+			switch {
+			case tt.wantKind == "" && err != nil:
+				t.Fatalf("VerifyKYC() error = %v", err)
+			case tt.wantKind != "" && !errors.As(err, &svcErr):
+				t.Fatalf("VerifyKYC() error = %v, want kind %q", err, tt.wantKind)
+			case tt.wantKind != "":
+				if svcErr.Kind != tt.wantKind || svcErr.Reason != tt.wantReason {
+					t.Errorf("VerifyKYC() error = %q %q, want %q %q", svcErr.Kind, svcErr.Reason, tt.wantKind, tt.wantReason)
+				}
+				result = svcErr.Result
+			default:
+				if result.Verified != tt.wantVerified || result.Reason != tt.wantReason {
+					t.Errorf("VerifyKYC() = verified %v reason %q, want %v %q", result.Verified, result.Reason, tt.wantVerified, tt.wantReason)
+				}
+			}
+
+			// Every outcome, including provider failures without a result, is
+			// recorded
+			page, err := fake.ListVerifications(ctx, repo.VerificationQuery{TenantID: req.TenantID, Limit: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(page.Records) != 1 || page.Records[0].Status != tt.wantStatus {
+				t.Errorf("ListVerifications() = %+v, want one record with status %q", page.Records, tt.wantStatus)
+			} else if result != nil && page.Records[0].ID != result.VerificationID {
+				t.Errorf("record ID = %q, want the result's verification ID %q", page.Records[0].ID, result.VerificationID)
+			}
+
+			attempts, err := fake.GetAttempts(ctx, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var failures int
+			if attempts != nil {
+				failures = failuresSince(attempts.History(), time.Time{})
+				if attempts.InFlight(time.Now()) && tt.wantReason != models.ReasonInProgress {
+					t.Error("GetAttempts() shows the identity still reserved, want the reservation released")
+				}
+			}
+			if failures != tt.wantFailures {
+				t.Errorf("failed attempts = %d, want %d", failures, tt.wantFailures)
+			}
+		})
+	}
+}
+
+// TestVerifyKYCConcurrentSubmissions runs two verifications of the same
+// identity at once. The provider delay keeps the first one in flight while
+// the second tries to reserve the identity.
+func TestVerifyKYCConcurrentSubmissions(t *testing.T) {
+	svc, _ := newTestService(t, config.Default())
+	req := models.KYCRequest{Email: "jane@example.com", TenantID: "acme"}
+	id := []byte(`{"delay": "100ms"}`)
+
+	errs := make(chan error, 2)
+	for range 2 {
+		go func() {
+			_, err := svc.VerifyKYC(context.Background(), id, []byte("selfie"), req)
+			errs <- err
+		}()
+	}
+
+	var succeeded, conflicted int
+	for range 2 {
+		err := <-errs
+		var svcErr *Error
+		switch {
+		case err == nil:
+			succeeded++
+		case errors.As(err, &svcErr) && svcErr.Kind == KindDuplicate && svcErr.Reason == models.ReasonInProgress:
+			conflicted++
+		default:
+			t.Errorf("VerifyKYC() error = %v, want success or a conflict", err)
+		}
+	}
+	if succeeded != 1 || conflicted != 1 {
+		t.Errorf("got %d verified and %d conflicts, want exactly one of each", succeeded, conflicted)
+	}
+}
+
+func TestVerifyKYCProfiles(t *testing.T) {
+	cfg := config.Default()
+	similarity := float32(99)
+	cfg.KYC.Profiles = map[string]config.ProfileConfig{
+		"strict":  {MinSimilarity: &similarity},
+		"lenient": {DisabledChecks: []string{"name_match"}},
+	}
+
+	// The fake document is issued to Jane Doe, with a selfie of 98% similarity
+	tests := []struct {
+		name     string
+		profile  string
+		fullName string
+
+		wantKind       ErrorKind
+		wantProfile    string
+		wantReason     models.ReasonCode
+		wantNameStatus models.CheckStatus
+	}{
+		{"kyc settings", "", "Jane Doe", "", config.DefaultProfileName, "", models.CheckPassed},
+		{"name mismatch", "", "John Smith", "", config.DefaultProfileName, models.ReasonNameMismatch, models.CheckFailed},
+		{"disabled check", "lenient", "John Smith", "", "lenient", "", models.CheckSkipped},
+		{"raised threshold", "strict", "Jane Doe", "", "strict", models.ReasonFaceMismatch, models.CheckPassed},
+		{"unknown profile", "relaxed", "Jane Doe", KindValidation, "", models.ReasonInvalidInput, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _ := newTestService(t, cfg)
+			req := models.KYCRequest{Email: "jane@example.com", FullName: tt.fullName, Profile: tt.profile}
+
+			result, err := svc.VerifyKYC(context.Background(), []byte("id"), []byte("selfie"), req)
+			var svcErr *Error
+			if tt.wantKind != "" {
+				if !errors.As(err, &svcErr) || svcErr.Kind != tt.wantKind || svcErr.Reason != tt.wantReason {
+					t.Errorf("VerifyKYC() error = %v, want kind %q and reason %q", err, tt.wantKind, tt.wantReason)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyKYC() error = %v", err)
+			}
+
+			if result.Profile != tt.wantProfile || result.Reason != tt.wantReason || result.Verified != (tt.wantReason == "") {
+				t.Errorf("VerifyKYC() = profile %q reason %q verified %v, want profile %q reason %q",
+					result.Profile, result.Reason, result.Verified, tt.wantProfile, tt.wantReason)
+			}
+			for _, check := range result.Checks {
+				if check.Name == models.CheckNameMatch && check.Status != tt.wantNameStatus {
+					t.Errorf("name_match check = %+v, want status %q", check, tt.wantNameStatus)
+				}
+			}
+		})
+	}
+}
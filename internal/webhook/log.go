@@ -0,0 +1,51 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/models"
+)
+
+// DeliveryLog records every webhook delivery attempt
+type DeliveryLog interface {
+	Record(ctx context.Context, delivery models.WebhookDelivery) error
+}
+
+// fileDeliveryLog appends deliveries to a JSON lines file
+type fileDeliveryLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFileDeliveryLog(path string) (DeliveryLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create delivery log directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open delivery log: %w", err)
+	}
+
+	return &fileDeliveryLog{file: file}, nil
+}
+
+func (l *fileDeliveryLog) Record(ctx context.Context, delivery models.WebhookDelivery) error {
+	line, err := json.Marshal(delivery)
+	if err != nil {
+		return fmt.Errorf("failed to encode delivery: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write delivery: %w", err)
+	}
+	return nil
+}
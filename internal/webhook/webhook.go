@@ -0,0 +1,318 @@
+// Package webhook delivers signed verification results to client callback URLs.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/models"
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/service"
+	"github.com/SwanHtetAungPhyo/kyc-api/pkg/config"
+	"github.com/SwanHtetAungPhyo/kyc-api/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// Headers sent with every delivery. Receivers verify a delivery by computing
+// HMAC-SHA256 over "<timestamp>.<body>" with their tenant's signing secret,
+// see Dispatcher.SigningSecret, and comparing it to the hex digest in
+// SignatureHeader.
+const (
+	SignatureHeader = "X-KYC-Signature"
+	TimestampHeader = "X-KYC-Timestamp"
+	EventIDHeader   = "X-KYC-Event-ID"
+)
+
+var (
+	ErrInvalidCallbackURL = errors.New("callback URL must be an absolute http or https URL")
+	// ErrForbiddenAddress is returned for callbacks to loopback, private,
+	// link-local and other non-public addresses. Deliveries are refused at
+	// connection time, so host names that resolve to such addresses are
+	// caught as well.
+	ErrForbiddenAddress = errors.New("callback URL must not point to a loopback, private or link-local address")
+)
+
+// reservedPrefixes are non-public ranges netip.Addr has no predicate for
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// publicAddress reports whether callbacks may connect to addr
+func publicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// refusePrivate is a net.Dialer Control hook that refuses connections to
+// non-public addresses once the host name has been resolved
+func refusePrivate(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil || !publicAddress(addrPort.Addr()) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
+
+// Dispatcher POSTs webhook events in the background, retrying failed
+// deliveries with exponential backoff
+type Dispatcher struct {
+	client         *http.Client
+	secret         []byte
+	allowPrivate   bool
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	deliveries     DeliveryLog
+	logger         logger.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewDispatcher(cfg *config.Config, deliveries DeliveryLog, log logger.Logger) *Dispatcher {
+	dialer := &net.Dialer{Timeout: cfg.Webhook.Timeout}
+	if !cfg.Webhook.AllowPrivateNetworks {
+		dialer.Control = refusePrivate
+	}
+	// No proxy is used, as it would be the proxy's address that is checked
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   cfg.Webhook.Timeout,
+			// Redirects are not followed, so a receiver cannot send the
+			// delivery on to an internal address. A 3xx fails the delivery.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		secret:         []byte(cfg.Webhook.Secret),
+		allowPrivate:   cfg.Webhook.AllowPrivateNetworks,
+		maxAttempts:    max(cfg.Webhook.MaxAttempts, 1),
+		initialBackoff: cfg.Webhook.InitialBackoff,
+		maxBackoff:     cfg.Webhook.MaxBackoff,
+		deliveries:     deliveries,
+		logger:         log,
+		ctx:            ctx,
+		cancel:         cancel,
+	}
+}
+
+// Enabled reports whether a signing secret is configured. Events are not
+// sent without one.
+func (d *Dispatcher) Enabled() bool {
+	return d != nil && len(d.secret) > 0
+}
+
+// SigningSecret returns the secret the tenant's events are signed with, the
+// hex HMAC-SHA256 of the tenant ID under WEBHOOK_SECRET. Each tenant gets its
+// own, so no tenant can forge events that another tenant's endpoint accepts,
+// and WEBHOOK_SECRET itself is never handed out.
+func (d *Dispatcher) SigningSecret(tenantID string) string {
+	mac := hmac.New(sha256.New, d.secret)
+	mac.Write([]byte("webhook-signing-secret:"))
+	mac.Write([]byte(tenantID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatch delivers the tenant's event to callbackURL in the background
+func (d *Dispatcher) Dispatch(tenantID, callbackURL string, event models.WebhookEvent) {
+	if !d.Enabled() || callbackURL == "" {
+		return
+	}
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		if err := d.Deliver(d.ctx, tenantID, callbackURL, event); err != nil {
+			d.logger.WithError(err).WithField("event_id", event.ID).Error("Webhook delivery failed")
+		}
+	}()
+}
+
+// Close abandons pending retries and waits for in-flight deliveries
+func (d *Dispatcher) Close() {
+	d.cancel()
+	d.wg.Wait()
+}
+
+// Deliver POSTs the tenant's event to callbackURL, retrying until it is
+// accepted with a 2xx response, the receiver rejects it permanently, or
+// attempts run out
+func (d *Dispatcher) Deliver(ctx context.Context, tenantID, callbackURL string, event models.WebhookEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook event: %w", err)
+	}
+	secret := []byte(d.SigningSecret(tenantID))
+
+	backoff := d.initialBackoff
+	for attempt := 1; ; attempt++ {
+		delivery, err := d.attempt(ctx, secret, callbackURL, event.ID, body)
+		delivery.Attempt = attempt
+
+		if err := d.deliveries.Record(ctx, delivery); err != nil {
+			d.logger.WithError(err).Error("Failed to record webhook delivery")
+		}
+
+		if delivery.Delivered {
+			return nil
+		}
+		if errors.Is(err, ErrForbiddenAddress) {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, ErrForbiddenAddress)
+		}
+		if attempt >= d.maxAttempts || !retryable(delivery.StatusCode) {
+			return fmt.Errorf("giving up after %d attempts: %s", attempt, deliveryError(delivery))
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff = min(backoff*2, d.maxBackoff)
+	}
+}
+
+// attempt makes a single delivery. The error is that of a delivery that got
+// no response.
+func (d *Dispatcher) attempt(ctx context.Context, secret []byte, callbackURL, eventID string, body []byte) (models.WebhookDelivery, error) {
+	delivery := models.WebhookDelivery{
+		EventID:     eventID,
+		URL:         callbackURL,
+		AttemptedAt: time.Now(),
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callbackURL, bytes.NewReader(body))
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery, err
+	}
+
+	timestamp := strconv.FormatInt(delivery.AttemptedAt.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventIDHeader, eventID)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, "sha256="+Sign(secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	delivery.Duration = time.Since(delivery.AttemptedAt)
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	delivery.StatusCode = resp.StatusCode
+	delivery.Delivered = resp.StatusCode >= 200 && resp.StatusCode < 300
+	return delivery, nil
+}
+
+// retryable reports whether a failed delivery is worth retrying. Network
+// errors (no status), timeouts, rate limiting and server errors are retried;
+// other client errors are permanent.
+func retryable(status int) bool {
+	return status == 0 ||
+		status == http.StatusRequestTimeout ||
+		status == http.StatusTooManyRequests ||
+		status >= 500
+}
+
+func deliveryError(delivery models.WebhookDelivery) string {
+	if delivery.Error != "" {
+		return delivery.Error
+	}
+	return fmt.Sprintf("receiver responded with status %d", delivery.StatusCode)
+}
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<body>"
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidateCallbackURL checks that a client-supplied callback URL is usable.
+// Hosts given as a non-public IP address or as localhost are rejected up
+// front; host names are checked again once resolved, when delivering.
+func (d *Dispatcher) ValidateCallbackURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return ErrInvalidCallbackURL
+	}
+	if d != nil && d.allowPrivate {
+		return nil
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrForbiddenAddress
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !publicAddress(addr) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
+
+// NewEvent builds the webhook event for a finished verification. Failures
+// only carry the sanitized details of a service error.
+func NewEvent(jobID, email string, result *models.VerificationResult, err error) models.WebhookEvent {
+	event := models.WebhookEvent{
+		ID:        uuid.NewString(),
+		Type:      models.EventVerificationCompleted,
+		CreatedAt: time.Now(),
+		JobID:     jobID,
+		Email:     email,
+		Result:    result,
+	}
+
+	if err != nil {
+		event.Type = models.EventVerificationFailed
+		event.Error = "Internal server error"
+
+		var svcErr *service.Error
+		if errors.As(err, &svcErr) {
+			event.Reason = svcErr.Reason
+			event.Error = svcErr.Message
+			event.Result = svcErr.Result
+		}
+	}
+
+	return event
+}
@@ -0,0 +1,184 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SwanHtetAungPhyo/kyc-api/internal/models"
+	"github.com/SwanHtetAungPhyo/kyc-api/pkg/config"
+	"github.com/SwanHtetAungPhyo/kyc-api/pkg/logger"
+)
+
+type memoryDeliveryLog struct {
+	mu         sync.Mutex
+	deliveries []models.WebhookDelivery
+}
+
+func (l *memoryDeliveryLog) Record(ctx context.Context, delivery models.WebhookDelivery) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.deliveries = append(l.deliveries, delivery)
+	return nil
+}
+
+func newTestDispatcher(allowPrivate bool) (*Dispatcher, *memoryDeliveryLog) {
+	cfg := config.Default()
+	cfg.Webhook.Secret = "0123456789abcdef0123456789abcdef"
+	cfg.Webhook.MaxAttempts = 3
+	cfg.Webhook.InitialBackoff = time.Millisecond
+	cfg.Webhook.MaxBackoff = time.Millisecond
+	cfg.Webhook.AllowPrivateNetworks = allowPrivate
+
+	deliveries := &memoryDeliveryLog{}
+	return NewDispatcher(cfg, deliveries, logger.NewLogger()), deliveries
+}
+
+func TestPublicAddress(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.0.0.1", false},
+		{"172.16.5.4", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"100.64.0.1", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:169.254.169.254", false},
+		{"64:ff9b::a9fe:a9fe", false},
+	}
+
+	for _, tt := range tests {
+		if got := publicAddress(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("publicAddress(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestValidateCallbackURL(t *testing.T) {
+	tests := []struct {
+		url          string
+		allowPrivate bool
+		want         error
+	}{
+		{"https://hooks.example.com/kyc", false, nil},
+		{"http://hooks.example.com:8080/kyc", false, nil},
+		{"ftp://hooks.example.com/kyc", false, ErrInvalidCallbackURL},
+		{"/kyc", false, ErrInvalidCallbackURL},
+		{"https://", false, ErrInvalidCallbackURL},
+		{"http://localhost:8080/kyc", false, ErrForbiddenAddress},
+		{"http://LOCALHOST./kyc", false, ErrForbiddenAddress},
+		{"http://api.localhost/kyc", false, ErrForbiddenAddress},
+		{"http://127.0.0.1/kyc", false, ErrForbiddenAddress},
+		{"http://169.254.169.254/latest/meta-data/", false, ErrForbiddenAddress},
+		{"http://10.1.2.3/kyc", false, ErrForbiddenAddress},
+		{"http://[::1]:3000/kyc", false, ErrForbiddenAddress},
+		{"http://localhost:8080/kyc", true, nil},
+		{"http://127.0.0.1/kyc", true, nil},
+	}
+
+	for _, tt := range tests {
+		d, _ := newTestDispatcher(tt.allowPrivate)
+		if got := d.ValidateCallbackURL(tt.url); !errors.Is(got, tt.want) {
+			t.Errorf("ValidateCallbackURL(%q) with allowPrivate=%v = %v, want %v", tt.url, tt.allowPrivate, got, tt.want)
+		}
+	}
+}
+
+func TestSigningSecret(t *testing.T) {
+	d, _ := newTestDispatcher(false)
+
+	acme, globex := d.SigningSecret("acme"), d.SigningSecret("globex")
+	if acme == globex {
+		t.Error("tenants share a signing secret")
+	}
+	if acme != d.SigningSecret("acme") {
+		t.Error("signing secret is not stable")
+	}
+	if legacy := d.SigningSecret(""); legacy == string(d.secret) || legacy == acme {
+		t.Error("keys without a tenant must get a derived secret of their own")
+	}
+}
+
+func TestDeliverRefusesPrivateAddress(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer server.Close()
+
+	d, deliveries := newTestDispatcher(false)
+	err := d.Deliver(context.Background(), "acme", server.URL, models.WebhookEvent{ID: "evt"})
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Fatalf("Deliver() error = %v, want the delivery refused", err)
+	}
+	if calls != 0 {
+		t.Errorf("receiver was called %d times, want none", calls)
+	}
+	if len(deliveries.deliveries) != 1 {
+		t.Errorf("recorded %d attempts, want 1 as refused addresses are not retried", len(deliveries.deliveries))
+	}
+}
+
+func TestDeliverSignsWithTenantSecret(t *testing.T) {
+	var signature, timestamp string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get(SignatureHeader)
+		timestamp = r.Header.Get(TimestampHeader)
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	d, _ := newTestDispatcher(true)
+	if err := d.Deliver(context.Background(), "acme", server.URL, models.WebhookEvent{ID: "evt"}); err != nil {
+		t.Fatalf("Deliver() error = %v", err)
+	}
+
+	if want := "sha256=" + Sign([]byte(d.SigningSecret("acme")), timestamp, body); signature != want {
+		t.Errorf("signature = %q, want it signed with the tenant's secret", signature)
+	}
+	if forged := "sha256=" + Sign([]byte(d.SigningSecret("globex")), timestamp, body); signature == forged {
+		t.Error("another tenant's secret produces the same signature")
+	}
+}
+
+func TestDeliverDoesNotFollowRedirects(t *testing.T) {
+	var redirected bool
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirected = true
+	}))
+	defer target.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusTemporaryRedirect)
+	}))
+	defer server.Close()
+
+	d, deliveries := newTestDispatcher(true)
+	if err := d.Deliver(context.Background(), "acme", server.URL, models.WebhookEvent{ID: "evt"}); err == nil {
+		t.Fatal("Deliver() succeeded, want a redirect to fail the delivery")
+	}
+	if redirected {
+		t.Error("redirect was followed")
+	}
+	if got := deliveries.deliveries[0].StatusCode; got != http.StatusTemporaryRedirect {
+		t.Errorf("StatusCode = %d, want %d", got, http.StatusTemporaryRedirect)
+	}
+}
@@ -0,0 +1,37 @@
+// Package boltdb opens the embedded bbolt databases the server keeps its
+// local stores in.
+package boltdb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Open opens the database at path, creating its directory, and creates
+// bucket. name describes the store in errors.
+func Open(path, name string, bucket []byte) (*bolt.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create %s directory: %w", name, err)
+	}
+
+	// Fail instead of blocking forever when another process holds the lock
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", name, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize %s: %w", name, err)
+	}
+
+	return db, nil
+}
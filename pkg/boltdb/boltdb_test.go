@@ -0,0 +1,34 @@
+package boltdb
+
+import (
+	"path/filepath"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "dir", "store.db")
+
+	db, err := Open(path, "test store", []byte("items"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer db.Close()
+
+	err = db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte("items")) == nil {
+			t.Error("bucket was not created")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A database held open elsewhere fails after the timeout
+	if second, err := Open(path, "test store", []byte("items")); err == nil {
+		second.Close()
+		t.Error("Open() of a locked database succeeded, want an error")
+	}
+}
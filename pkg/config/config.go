@@ -1,46 +1,641 @@
 package config
 
-import "os"
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the complete service configuration. It is built from Default,
+// then the YAML or TOML file named by CONFIG_FILE, then environment
+// variables, each layer overriding the previous one.
 type Config struct {
-	AWS    AWSConfig
-	Server ServerConfig
-	JWT    JWTConfig
+	// Environment is "production" or "development". Production refuses to
+	// start with insecure settings, see Validate.
+	Environment   string              `yaml:"environment" toml:"environment"`
+	Provider      ProviderConfig      `yaml:"provider" toml:"provider"`
+	AWS           AWSConfig           `yaml:"aws" toml:"aws"`
+	Attempts      AttemptsConfig      `yaml:"attempts" toml:"attempts"`
+	Verifications VerificationsConfig `yaml:"verifications" toml:"verifications"`
+	Server        ServerConfig        `yaml:"server" toml:"server"`
+	RateLimit     RateLimitConfig     `yaml:"rate_limit" toml:"rate_limit"`
+	JWT           JWTConfig           `yaml:"jwt" toml:"jwt"`
+	KYC           KYCConfig           `yaml:"kyc" toml:"kyc"`
+	Jobs          JobsConfig          `yaml:"jobs" toml:"jobs"`
+	Webhook       WebhookConfig       `yaml:"webhook" toml:"webhook"`
+}
+
+const (
+	EnvProduction  = "production"
+	EnvDevelopment = "development"
+)
+
+const (
+	ProviderAWS  = "aws"
+	ProviderFake = "fake"
+)
+
+// ProviderConfig selects the document and face verification backend
+type ProviderConfig struct {
+	// Name is "aws" or "fake"; the fake provider runs fully offline
+	Name string `yaml:"name" toml:"name"`
+	// FixturesDir optionally holds fake scenarios named <sha256 of image>.json
+	FixturesDir string `yaml:"fixtures_dir" toml:"fixtures_dir"`
 }
 
+// AWSConfig selects how the AWS clients authenticate and connect. Without
+// static keys the SDK's default credential chain is used: environment,
+// shared config and SSO profiles, web identity (IRSA), ECS task roles and
+// EC2 instance roles.
 type AWSConfig struct {
-	AccessKeyID     string
-	SecretAccessKey string
-	Region          string
+	// AccessKeyID, SecretAccessKey and SessionToken are optional static
+	// credentials
+	AccessKeyID     string `yaml:"access_key_id" toml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key" toml:"secret_access_key"`
+	SessionToken    string `yaml:"session_token" toml:"session_token"`
+	// Region defaults to the region of the shared config profile, then to
+	// us-east-1
+	Region string `yaml:"region" toml:"region"`
+	// Profile is the shared config profile to load
+	Profile string `yaml:"profile" toml:"profile"`
+	// AssumeRoleARN is a role assumed with the credentials above
+	AssumeRoleARN         string `yaml:"assume_role_arn" toml:"assume_role_arn"`
+	AssumeRoleExternalID  string `yaml:"assume_role_external_id" toml:"assume_role_external_id"`
+	AssumeRoleSessionName string `yaml:"assume_role_session_name" toml:"assume_role_session_name"`
+	// EndpointURL overrides the endpoint of every AWS service, e.g. to use
+	// LocalStack
+	EndpointURL string `yaml:"endpoint_url" toml:"endpoint_url"`
+}
+
+const (
+	AttemptStoreDynamoDB = "dynamodb"
+	AttemptStoreBolt     = "bolt"
+)
+
+// AttemptsConfig selects where verification attempts are recorded
+type AttemptsConfig struct {
+	// Store is "dynamodb" or "bolt"; empty uses the provider's own store
+	// (DynamoDB for aws, in memory for fake)
+	Store string `yaml:"store" toml:"store"`
+	// Table is the DynamoDB table name
+	Table string `yaml:"table" toml:"table"`
+	// Path is the bolt database file
+	Path string `yaml:"path" toml:"path"`
+	// OnFailure is what happens when an attempt cannot be recorded:
+	// "fail" fails the verification request, "retry" answers it and keeps
+	// retrying the write in the background
+	OnFailure string `yaml:"on_failure" toml:"on_failure"`
+	// Retry controls the background writes of the "retry" policy
+	Retry AttemptRetryConfig `yaml:"retry" toml:"retry"`
+	// Lease is how long a verification in flight holds its identity before
+	// another submission may take over, e.g. after a crash
+	Lease time.Duration `yaml:"lease" toml:"lease"`
+}
+
+const (
+	AttemptFailureFail  = "fail"
+	AttemptFailureRetry = "retry"
+)
+
+type AttemptRetryConfig struct {
+	MaxAttempts    int           `yaml:"max_attempts" toml:"max_attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff" toml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff" toml:"max_backoff"`
+	// MaxPending bounds the writes being retried; further failures fail
+	// their request
+	MaxPending int `yaml:"max_pending" toml:"max_pending"`
+}
+
+// VerificationsConfig selects where verification records are kept
+type VerificationsConfig struct {
+	// Store is "dynamodb" or "bolt", like AttemptsConfig.Store
+	Store string `yaml:"store" toml:"store"`
+	// Table is the DynamoDB table name
+	Table string `yaml:"table" toml:"table"`
+	// Path is the bolt database file
+	Path string `yaml:"path" toml:"path"`
+	// EncryptionKey seals the identifying document fields of records.
+	// Changing it makes the sealed fields of existing records unreadable.
+	EncryptionKey string `yaml:"encryption_key" toml:"encryption_key"`
 }
 
 type ServerConfig struct {
-	Port string
+	Port string `yaml:"port" toml:"port"`
+	// CORSOrigins is a comma separated list of allowed origins, or "*".
+	// Defaults to "*" in development and to no cross-origin access otherwise.
+	CORSOrigins string `yaml:"cors_origins" toml:"cors_origins"`
+}
+
+// RateLimitConfig limits requests per Window. Every route is limited per
+// client IP, and KYC routes also per tenant.
+type RateLimitConfig struct {
+	Max       int           `yaml:"max" toml:"max"`
+	TenantMax int           `yaml:"tenant_max" toml:"tenant_max"`
+	Window    time.Duration `yaml:"window" toml:"window"`
 }
 
 type JWTConfig struct {
-	Secret string
+	// Secret signs API keys when Keys is not set, and verifies tokens issued
+	// without a kid. Development servers without any key get a random one.
+	Secret string `yaml:"secret" toml:"secret"`
+	// Keys are the HMAC signing keys by kid. Tokens signed with any of them
+	// are accepted; new tokens are signed with ActiveKeyID.
+	Keys map[string]string `yaml:"keys" toml:"keys"`
+	// PrivateKeyFiles are PEM encoded RSA, ECDSA or Ed25519 private keys by
+	// kid, signing with RS256, ES256/384/512 or EdDSA
+	PrivateKeyFiles map[string]string `yaml:"private_key_files" toml:"private_key_files"`
+	// PublicKeyFiles are PEM encoded public keys by kid that only verify
+	// tokens, e.g. of a retired private key
+	PublicKeyFiles map[string]string `yaml:"public_key_files" toml:"public_key_files"`
+	ActiveKeyID    string            `yaml:"active_key_id" toml:"active_key_id"`
+	// KeyStorePath is the database issued API keys and revocations are
+	// recorded in
+	KeyStorePath string `yaml:"key_store_path" toml:"key_store_path"`
+	// TTL is how long issued API keys stay valid
+	TTL time.Duration `yaml:"ttl" toml:"ttl"`
+	// AdminToken authorizes API key issuance; issuance is disabled when empty
+	AdminToken string `yaml:"admin_token" toml:"admin_token"`
 }
 
-func Load() (*Config, error) {
+// KYCConfig holds verification policy settings
+type KYCConfig struct {
+	// MinAge is the minimum applicant age in years; 0 disables the age gate
+	MinAge int `yaml:"min_age" toml:"min_age"`
+	// MinAgeByJurisdiction overrides MinAge for documents issued by the given
+	// US state or, for documents with an MRZ, country. Keys are normalized
+	// with JurisdictionCode.
+	MinAgeByJurisdiction map[string]int `yaml:"min_age_by_jurisdiction" toml:"min_age_by_jurisdiction"`
+	Face                 FaceCriteria   `yaml:"face" toml:"face"`
+	Retry                RetryConfig    `yaml:"retry" toml:"retry"`
+	// Profiles are named policies with their own thresholds and checks,
+	// selected per API key or per request, see Policy
+	Profiles map[string]ProfileConfig `yaml:"profiles" toml:"profiles"`
+	// DefaultProfile applies when neither the API key nor the request names
+	// a profile. Empty means the settings above.
+	DefaultProfile string `yaml:"default_profile" toml:"default_profile"`
+}
+
+// FaceCriteria are the minimum face detection and comparison scores, from 0
+// to 100
+type FaceCriteria struct {
+	MinConfidence float32 `yaml:"min_confidence" toml:"min_confidence"`
+	MinBrightness float32 `yaml:"min_brightness" toml:"min_brightness"`
+	MinSharpness  float32 `yaml:"min_sharpness" toml:"min_sharpness"`
+	MinSimilarity float32 `yaml:"min_similarity" toml:"min_similarity"`
+}
+
+// RetryConfig limits failed verification attempts per identity
+type RetryConfig struct {
+	// MaxFailures failed attempts within Window lock the identity; 0
+	// allows unlimited retries
+	MaxFailures int           `yaml:"max_failures" toml:"max_failures"`
+	Window      time.Duration `yaml:"window" toml:"window"`
+	// Lockout is how long a locked identity stays locked after its last
+	// failure; 0 locks it until its attempt record is removed
+	Lockout time.Duration `yaml:"lockout" toml:"lockout"`
+}
+
+// JobsConfig controls asynchronous verification jobs
+type JobsConfig struct {
+	// Dir is where job records and their uploaded images are stored
+	Dir       string `yaml:"dir" toml:"dir"`
+	Workers   int    `yaml:"workers" toml:"workers"`
+	QueueSize int    `yaml:"queue_size" toml:"queue_size"`
+	// Timeout bounds the processing time of a single job
+	Timeout time.Duration `yaml:"timeout" toml:"timeout"`
+	// Retention is how long finished jobs are kept before they are deleted
+	Retention time.Duration `yaml:"retention" toml:"retention"`
+}
+
+// WebhookConfig controls signed callbacks sent when a verification finishes
+type WebhookConfig struct {
+	// Secret derives the per-tenant HMAC-SHA256 keys payloads are signed
+	// with; webhooks are disabled when it is empty
+	Secret         string        `yaml:"secret" toml:"secret"`
+	MaxAttempts    int           `yaml:"max_attempts" toml:"max_attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff" toml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff" toml:"max_backoff"`
+	Timeout        time.Duration `yaml:"timeout" toml:"timeout"`
+	// LogPath is the JSON lines file every delivery attempt is appended to
+	LogPath string `yaml:"log_path" toml:"log_path"`
+	// AllowPrivateNetworks permits callbacks to loopback, private and
+	// link-local addresses, for receivers on a development machine
+	AllowPrivateNetworks bool `yaml:"allow_private_networks" toml:"allow_private_networks"`
+}
+
+// Default returns the built-in configuration
+func Default() *Config {
 	return &Config{
+		Environment: EnvProduction,
+		Provider: ProviderConfig{
+			Name: ProviderAWS,
+		},
 		AWS: AWSConfig{
-			AccessKeyID:     getEnv("AWS_ACCESS_KEY_ID", ""),
-			SecretAccessKey: getEnv("AWS_SECRET_ACCESS_KEY", ""),
-			Region:          getEnv("AWS_REGION", "us-east-1"),
+			AssumeRoleSessionName: "kyc-api",
+		},
+		Attempts: AttemptsConfig{
+			Path:      "data/attempts.db",
+			OnFailure: AttemptFailureFail,
+			Lease:     5 * time.Minute,
+			Retry: AttemptRetryConfig{
+				MaxAttempts:    10,
+				InitialBackoff: time.Second,
+				MaxBackoff:     time.Minute,
+				MaxPending:     1000,
+			},
+		},
+		Verifications: VerificationsConfig{
+			Path: "data/verifications.db",
 		},
 		Server: ServerConfig{
-			Port: getEnv("PORT", "3001"),
+			Port: "3001",
+		},
+		RateLimit: RateLimitConfig{
+			Max:       10,
+			TenantMax: 10,
+			Window:    time.Minute,
 		},
 		JWT: JWTConfig{
-			Secret: getEnv("JWT_SECRET", "yqKmE7cB7OWpouhuR/x/11HMjx/0Ki5cwwN756K2/dM="),
+			KeyStorePath: "data/api_keys.db",
+			TTL:          30 * 24 * time.Hour,
+		},
+		KYC: KYCConfig{
+			MinAge: 18,
+			Face: FaceCriteria{
+				MinConfidence: 90,
+				MinBrightness: 50,
+				MinSharpness:  50,
+				MinSimilarity: 70,
+			},
+			Retry: RetryConfig{
+				MaxFailures: 5,
+				Window:      24 * time.Hour,
+				Lockout:     24 * time.Hour,
+			},
+		},
+		Jobs: JobsConfig{
+			Dir:       "data/jobs",
+			Workers:   4,
+			QueueSize: 100,
+			Timeout:   2 * time.Minute,
+			Retention: 7 * 24 * time.Hour,
+		},
+		Webhook: WebhookConfig{
+			MaxAttempts:    5,
+			InitialBackoff: time.Second,
+			MaxBackoff:     time.Minute,
+			Timeout:        10 * time.Second,
+			LogPath:        "data/webhook_deliveries.jsonl",
 		},
-	}, nil
+	}
+}
+
+// Load builds the configuration from the defaults, the optional CONFIG_FILE
+// and the environment, and validates it. All problems are reported at once,
+// joined into a single error.
+func Load() (*Config, error) {
+	cfg := Default()
+
+	if path := getEnv("CONFIG_FILE", ""); path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	errs := cfg.applyEnv()
+	cfg.applyDerivedDefaults()
+
+	errs = append(errs, cfg.Validate())
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	// Keys are normalized once validation has ruled out duplicates, so
+	// "Mississippi" in a file matches documents and overrides given as "MS"
+	cfg.KYC.normalizeJurisdictions()
+	return cfg, nil
+}
+
+// loadFile overlays the YAML or, for a .toml extension, TOML file at path.
+// Unknown keys are rejected so that typos don't silently fall back to
+// defaults.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		meta, err := toml.Decode(string(data), c)
+		if err != nil {
+			return fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("invalid config file %s: unknown setting %s", path, undecoded[0])
+		}
+		return nil
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnv overrides settings with the environment variables that are set
+func (c *Config) applyEnv() []error {
+	var errs []error
+	var err error
+
+	c.Environment = getEnv("APP_ENV", c.Environment)
+	c.Provider.Name = getEnv("PROVIDER", c.Provider.Name)
+	c.Provider.FixturesDir = getEnv("FAKE_FIXTURES_DIR", c.Provider.FixturesDir)
+
+	c.AWS.AccessKeyID = getEnv("AWS_ACCESS_KEY_ID", c.AWS.AccessKeyID)
+	c.AWS.SecretAccessKey = getEnv("AWS_SECRET_ACCESS_KEY", c.AWS.SecretAccessKey)
+	c.AWS.SessionToken = getEnv("AWS_SESSION_TOKEN", c.AWS.SessionToken)
+	c.AWS.Region = getEnv("AWS_REGION", c.AWS.Region)
+	c.AWS.Profile = getEnv("AWS_PROFILE", c.AWS.Profile)
+	c.AWS.AssumeRoleARN = getEnv("AWS_ASSUME_ROLE_ARN", c.AWS.AssumeRoleARN)
+	c.AWS.AssumeRoleExternalID = getEnv("AWS_ASSUME_ROLE_EXTERNAL_ID", c.AWS.AssumeRoleExternalID)
+	c.AWS.AssumeRoleSessionName = getEnv("AWS_ASSUME_ROLE_SESSION_NAME", c.AWS.AssumeRoleSessionName)
+	c.AWS.EndpointURL = getEnv("AWS_ENDPOINT_URL", c.AWS.EndpointURL)
+
+	c.Attempts.Store = getEnv("ATTEMPT_STORE", c.Attempts.Store)
+	c.Attempts.Table = getEnv("KYC_RECORD", c.Attempts.Table)
+	c.Attempts.Path = getEnv("ATTEMPT_DB_PATH", c.Attempts.Path)
+	c.Attempts.OnFailure = getEnv("ATTEMPT_ON_FAILURE", c.Attempts.OnFailure)
+	c.Attempts.Lease, err = getEnvDuration("ATTEMPT_LEASE", c.Attempts.Lease)
+	errs = append(errs, err)
+	c.Attempts.Retry.MaxAttempts, err = getEnvInt("ATTEMPT_RETRY_MAX_ATTEMPTS", c.Attempts.Retry.MaxAttempts)
+	errs = append(errs, err)
+	c.Attempts.Retry.InitialBackoff, err = getEnvDuration("ATTEMPT_RETRY_INITIAL_BACKOFF", c.Attempts.Retry.InitialBackoff)
+	errs = append(errs, err)
+	c.Attempts.Retry.MaxBackoff, err = getEnvDuration("ATTEMPT_RETRY_MAX_BACKOFF", c.Attempts.Retry.MaxBackoff)
+	errs = append(errs, err)
+	c.Attempts.Retry.MaxPending, err = getEnvInt("ATTEMPT_RETRY_MAX_PENDING", c.Attempts.Retry.MaxPending)
+	errs = append(errs, err)
+
+	c.Verifications.Store = getEnv("VERIFICATION_STORE", c.Verifications.Store)
+	c.Verifications.Table = getEnv("VERIFICATION_TABLE", c.Verifications.Table)
+	c.Verifications.Path = getEnv("VERIFICATION_DB_PATH", c.Verifications.Path)
+	c.Verifications.EncryptionKey = getEnv("RECORD_ENCRYPTION_KEY", c.Verifications.EncryptionKey)
+
+	c.Server.Port = getEnv("PORT", c.Server.Port)
+	c.Server.CORSOrigins = getEnv("CORS_ALLOWED_ORIGINS", c.Server.CORSOrigins)
+
+	c.RateLimit.Max, err = getEnvInt("RATE_LIMIT_MAX", c.RateLimit.Max)
+	errs = append(errs, err)
+	c.RateLimit.TenantMax, err = getEnvInt("RATE_LIMIT_TENANT_MAX", c.RateLimit.TenantMax)
+	errs = append(errs, err)
+	c.RateLimit.Window, err = getEnvDuration("RATE_LIMIT_WINDOW", c.RateLimit.Window)
+	errs = append(errs, err)
+
+	c.JWT.Secret = getEnv("JWT_SECRET", c.JWT.Secret)
+	c.JWT.Keys, err = getEnvStringMap("JWT_KEYS", c.JWT.Keys)
+	errs = append(errs, err)
+	c.JWT.PrivateKeyFiles, err = getEnvStringMap("JWT_PRIVATE_KEYS", c.JWT.PrivateKeyFiles)
+	errs = append(errs, err)
+	c.JWT.PublicKeyFiles, err = getEnvStringMap("JWT_PUBLIC_KEYS", c.JWT.PublicKeyFiles)
+	errs = append(errs, err)
+	c.JWT.ActiveKeyID = getEnv("JWT_ACTIVE_KEY_ID", c.JWT.ActiveKeyID)
+	c.JWT.KeyStorePath = getEnv("API_KEY_DB_PATH", c.JWT.KeyStorePath)
+	c.JWT.TTL, err = getEnvDuration("JWT_TTL", c.JWT.TTL)
+	errs = append(errs, err)
+	c.JWT.AdminToken = getEnv("ADMIN_TOKEN", c.JWT.AdminToken)
+
+	c.KYC.MinAge, err = getEnvInt("MIN_AGE", c.KYC.MinAge)
+	errs = append(errs, err)
+	c.KYC.MinAgeByJurisdiction, err = getEnvIntMap("MIN_AGE_BY_JURISDICTION", c.KYC.MinAgeByJurisdiction)
+	errs = append(errs, err)
+	c.KYC.DefaultProfile = getEnv("KYC_DEFAULT_PROFILE", c.KYC.DefaultProfile)
+	c.KYC.Face.MinConfidence, err = getEnvFloat32("FACE_MIN_CONFIDENCE", c.KYC.Face.MinConfidence)
+	errs = append(errs, err)
+	c.KYC.Face.MinBrightness, err = getEnvFloat32("FACE_MIN_BRIGHTNESS", c.KYC.Face.MinBrightness)
+	errs = append(errs, err)
+	c.KYC.Face.MinSharpness, err = getEnvFloat32("FACE_MIN_SHARPNESS", c.KYC.Face.MinSharpness)
+	errs = append(errs, err)
+	c.KYC.Face.MinSimilarity, err = getEnvFloat32("FACE_MIN_SIMILARITY", c.KYC.Face.MinSimilarity)
+	errs = append(errs, err)
+
+	c.KYC.Retry.MaxFailures, err = getEnvInt("KYC_MAX_FAILED_ATTEMPTS", c.KYC.Retry.MaxFailures)
+	errs = append(errs, err)
+	c.KYC.Retry.Window, err = getEnvDuration("KYC_ATTEMPT_WINDOW", c.KYC.Retry.Window)
+	errs = append(errs, err)
+	c.KYC.Retry.Lockout, err = getEnvDuration("KYC_ATTEMPT_LOCKOUT", c.KYC.Retry.Lockout)
+	errs = append(errs, err)
+
+	c.Jobs.Dir = getEnv("JOB_DIR", c.Jobs.Dir)
+	c.Jobs.Workers, err = getEnvInt("JOB_WORKERS", c.Jobs.Workers)
+	errs = append(errs, err)
+	c.Jobs.QueueSize, err = getEnvInt("JOB_QUEUE_SIZE", c.Jobs.QueueSize)
+	errs = append(errs, err)
+	c.Jobs.Timeout, err = getEnvDuration("JOB_TIMEOUT", c.Jobs.Timeout)
+	errs = append(errs, err)
+	c.Jobs.Retention, err = getEnvDuration("JOB_RETENTION", c.Jobs.Retention)
+	errs = append(errs, err)
+
+	c.Webhook.Secret = getEnv("WEBHOOK_SECRET", c.Webhook.Secret)
+	c.Webhook.MaxAttempts, err = getEnvInt("WEBHOOK_MAX_ATTEMPTS", c.Webhook.MaxAttempts)
+	errs = append(errs, err)
+	c.Webhook.InitialBackoff, err = getEnvDuration("WEBHOOK_INITIAL_BACKOFF", c.Webhook.InitialBackoff)
+	errs = append(errs, err)
+	c.Webhook.MaxBackoff, err = getEnvDuration("WEBHOOK_MAX_BACKOFF", c.Webhook.MaxBackoff)
+	errs = append(errs, err)
+	c.Webhook.Timeout, err = getEnvDuration("WEBHOOK_TIMEOUT", c.Webhook.Timeout)
+	errs = append(errs, err)
+	c.Webhook.LogPath = getEnv("WEBHOOK_LOG_PATH", c.Webhook.LogPath)
+	c.Webhook.AllowPrivateNetworks, err = getEnvBool("WEBHOOK_ALLOW_PRIVATE_NETWORKS", c.Webhook.AllowPrivateNetworks)
+	errs = append(errs, err)
+
+	return errs
 }
 
+// applyDerivedDefaults fills in settings whose defaults depend on others
+func (c *Config) applyDerivedDefaults() {
+	c.Environment = strings.ToLower(c.Environment)
+	c.Provider.Name = strings.ToLower(c.Provider.Name)
+	c.Attempts.Store = strings.ToLower(c.Attempts.Store)
+	c.Attempts.OnFailure = strings.ToLower(c.Attempts.OnFailure)
+	c.Verifications.Store = strings.ToLower(c.Verifications.Store)
+
+	// Like the signing key, records sealed by a development server can only
+	// be read until the next restart
+	if c.Verifications.EncryptionKey == "" && c.Environment == EnvDevelopment {
+		c.Verifications.EncryptionKey = developmentSecret()
+	}
+
+	// Development servers without any signing key get a random secret, so
+	// API keys only last until the next restart
+	if c.JWT.Secret == "" && len(c.JWT.Keys) == 0 && len(c.JWT.PrivateKeyFiles) == 0 && c.Environment == EnvDevelopment {
+		c.JWT.Secret = developmentSecret()
+	}
+	// Without a key set the single secret is used under the kid that also
+	// verifies tokens issued before rotation was supported
+	if len(c.JWT.Keys) == 0 && c.JWT.Secret != "" {
+		c.JWT.Keys = map[string]string{"default": c.JWT.Secret}
+	}
+	if c.JWT.ActiveKeyID == "" {
+		c.JWT.ActiveKeyID = "default"
+		if len(c.JWT.PrivateKeyFiles) == 1 {
+			for kid := range c.JWT.PrivateKeyFiles {
+				c.JWT.ActiveKeyID = kid
+			}
+		}
+	}
+
+	if c.Server.CORSOrigins == "" && c.Environment == EnvDevelopment {
+		c.Server.CORSOrigins = "*"
+	}
+}
+
+// developmentSecret is generated once per process so configuration reloads
+// keep the same key
+var developmentSecret = sync.OnceValue(func() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("failed to generate secret: %v", err))
+	}
+	return base64.StdEncoding.EncodeToString(b)
+})
+
 func getEnv(key, fallback string) string {
 	if value, exists := os.LookupEnv(key); exists {
 		return value
 	}
 	return fallback
 }
+
+func getEnvInt(key string, fallback int) (int, error) {
+	value, exists := os.LookupEnv(key)
+	if !exists || value == "" {
+		return fallback, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return n, nil
+}
+
+func getEnvBool(key string, fallback bool) (bool, error) {
+	value, exists := os.LookupEnv(key)
+	if !exists || value == "" {
+		return fallback, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return b, nil
+}
+
+func getEnvFloat32(key string, fallback float32) (float32, error) {
+	value, exists := os.LookupEnv(key)
+	if !exists || value == "" {
+		return fallback, nil
+	}
+
+	f, err := strconv.ParseFloat(value, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return float32(f), nil
+}
+
+func getEnvIntMap(key string, fallback map[string]int) (map[string]int, error) {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback, nil
+	}
+
+	m, err := parseIntMap(value)
+	if err != nil {
+		return fallback, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return m, nil
+}
+
+func getEnvStringMap(key string, fallback map[string]string) (map[string]string, error) {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback, nil
+	}
+
+	m, err := parseStringMap(value)
+	if err != nil {
+		return fallback, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return m, nil
+}
+
+func getEnvDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value, exists := os.LookupEnv(key)
+	if !exists || value == "" {
+		return fallback, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return d, nil
+}
+
+// parseIntMap parses "KEY:value,KEY:value" pairs, upper-casing the keys
+func parseIntMap(raw string) (map[string]int, error) {
+	result := make(map[string]int)
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(pair, ":")
+		if !ok {
+			return nil, fmt.Errorf("expected KEY:value, got %q", pair)
+		}
+
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %w", key, err)
+		}
+		result[strings.ToUpper(strings.TrimSpace(key))] = n
+	}
+	return result, nil
+}
+
+// parseStringMap parses "key:value,key:value" pairs. Values may contain ':'
+// but not ','.
+func parseStringMap(raw string) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(pair, ":")
+		if !ok || strings.TrimSpace(key) == "" {
+			// Don't echo the pair, it may contain a secret
+			return nil, fmt.Errorf("expected key:value pairs separated by commas")
+		}
+		result[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return result, nil
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// usStates maps the names of US states and territories, as printed on
+// driver's licenses and state IDs, to their postal codes
+var usStates = map[string]string{
+	"ALABAMA":                  "AL",
+	"ALASKA":                   "AK",
+	"ARIZONA":                  "AZ",
+	"ARKANSAS":                 "AR",
+	"CALIFORNIA":               "CA",
+	"COLORADO":                 "CO",
+	"CONNECTICUT":              "CT",
+	"DELAWARE":                 "DE",
+	"DISTRICT OF COLUMBIA":     "DC",
+	"FLORIDA":                  "FL",
+	"GEORGIA":                  "GA",
+	"HAWAII":                   "HI",
+	"IDAHO":                    "ID",
+	"ILLINOIS":                 "IL",
+	"INDIANA":                  "IN",
+	"IOWA":                     "IA",
+	"KANSAS":                   "KS",
+	"KENTUCKY":                 "KY",
+	"LOUISIANA":                "LA",
+	"MAINE":                    "ME",
+	"MARYLAND":                 "MD",
+	"MASSACHUSETTS":            "MA",
+	"MICHIGAN":                 "MI",
+	"MINNESOTA":                "MN",
+	"MISSISSIPPI":              "MS",
+	"MISSOURI":                 "MO",
+	"MONTANA":                  "MT",
+	"NEBRASKA":                 "NE",
+	"NEVADA":                   "NV",
+	"NEW HAMPSHIRE":            "NH",
+	"NEW JERSEY":               "NJ",
+	"NEW MEXICO":               "NM",
+	"NEW YORK":                 "NY",
+	"NORTH CAROLINA":           "NC",
+	"NORTH DAKOTA":             "ND",
+	"OHIO":                     "OH",
+	"OKLAHOMA":                 "OK",
+	"OREGON":                   "OR",
+	"PENNSYLVANIA":             "PA",
+	"RHODE ISLAND":             "RI",
+	"SOUTH CAROLINA":           "SC",
+	"SOUTH DAKOTA":             "SD",
+	"TENNESSEE":                "TN",
+	"TEXAS":                    "TX",
+	"UTAH":                     "UT",
+	"VERMONT":                  "VT",
+	"VIRGINIA":                 "VA",
+	"WASHINGTON":               "WA",
+	"WEST VIRGINIA":            "WV",
+	"WISCONSIN":                "WI",
+	"WYOMING":                  "WY",
+	"AMERICAN SAMOA":           "AS",
+	"GUAM":                     "GU",
+	"NORTHERN MARIANA ISLANDS": "MP",
+	"PUERTO RICO":              "PR",
+	"US VIRGIN ISLANDS":        "VI",
+	"U.S. VIRGIN ISLANDS":      "VI",
+}
+
+// JurisdictionCode normalizes an issuing state or country for looking up
+// MinAgeByJurisdiction: it is upper-cased and US state names are replaced by
+// their postal code, so "Mississippi" and "ms" both become "MS". Other
+// values, such as ICAO country codes, are only upper-cased.
+func JurisdictionCode(name string) string {
+	name = strings.ToUpper(strings.Join(strings.Fields(name), " "))
+	if code, ok := usStates[name]; ok {
+		return code
+	}
+	return name
+}
+
+// normalizeJurisdictions rewrites the keys of MinAgeByJurisdiction, in the
+// kyc section and in every profile, with JurisdictionCode
+func (k *KYCConfig) normalizeJurisdictions() {
+	k.MinAgeByJurisdiction = normalizeJurisdictionKeys(k.MinAgeByJurisdiction)
+	for name, profile := range k.Profiles {
+		profile.MinAgeByJurisdiction = normalizeJurisdictionKeys(profile.MinAgeByJurisdiction)
+		k.Profiles[name] = profile
+	}
+}
+
+func normalizeJurisdictionKeys(ages map[string]int) map[string]int {
+	if ages == nil {
+		return nil
+	}
+	normalized := make(map[string]int, len(ages))
+	for jurisdiction, age := range ages {
+		normalized[JurisdictionCode(jurisdiction)] = age
+	}
+	return normalized
+}
+
+// jurisdictionConflicts reports jurisdictions that are listed more than once
+// under different spellings, e.g. both "MS" and "Mississippi"
+func jurisdictionConflicts(ages map[string]int) []string {
+	seen := make(map[string]string, len(ages))
+	var conflicts []string
+	for _, jurisdiction := range slices.Sorted(maps.Keys(ages)) {
+		code := JurisdictionCode(jurisdiction)
+		if other, ok := seen[code]; ok {
+			conflicts = append(conflicts, fmt.Sprintf("%q and %q", other, jurisdiction))
+			continue
+		}
+		seen[code] = jurisdiction
+	}
+	return conflicts
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestJurisdictionCode(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"MS", "MS"},
+		{"ms", "MS"},
+		{"MISSISSIPPI", "MS"},
+		{"Mississippi", "MS"},
+		{" new   york ", "NY"},
+		{"District of Columbia", "DC"},
+		{"GBR", "GBR"},
+		{"d", "D"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := JurisdictionCode(tt.name); got != tt.want {
+			t.Errorf("JurisdictionCode(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeJurisdictions(t *testing.T) {
+	k := KYCConfig{
+		MinAgeByJurisdiction: map[string]int{"Mississippi": 21, "al": 19, "GBR": 18},
+		Profiles: map[string]ProfileConfig{
+			"regulated": {MinAgeByJurisdiction: map[string]int{"new york": 21}},
+			"low-risk":  {},
+		},
+	}
+	k.normalizeJurisdictions()
+
+	if want := map[string]int{"MS": 21, "AL": 19, "GBR": 18}; !reflect.DeepEqual(k.MinAgeByJurisdiction, want) {
+		t.Errorf("MinAgeByJurisdiction = %v, want %v", k.MinAgeByJurisdiction, want)
+	}
+	if want := map[string]int{"NY": 21}; !reflect.DeepEqual(k.Profiles["regulated"].MinAgeByJurisdiction, want) {
+		t.Errorf("regulated MinAgeByJurisdiction = %v, want %v", k.Profiles["regulated"].MinAgeByJurisdiction, want)
+	}
+	if k.Profiles["low-risk"].MinAgeByJurisdiction != nil {
+		t.Errorf("low-risk MinAgeByJurisdiction = %v, want nil so the kyc setting applies", k.Profiles["low-risk"].MinAgeByJurisdiction)
+	}
+}
+
+func TestJurisdictionConflicts(t *testing.T) {
+	tests := []struct {
+		ages map[string]int
+		want []string
+	}{
+		{nil, nil},
+		{map[string]int{"MS": 21, "AL": 19}, nil},
+		{map[string]int{"MS": 21, "Mississippi": 21}, []string{`"MS" and "Mississippi"`}},
+		{map[string]int{"gbr": 18, "GBR": 18}, []string{`"GBR" and "gbr"`}},
+	}
+
+	for _, tt := range tests {
+		if got := jurisdictionConflicts(tt.ages); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("jurisdictionConflicts(%v) = %q, want %q", tt.ages, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// Live holds the running configuration. Reload applies changes to the
+// settings that are safe to change at runtime; everything else is structural
+// (listeners, stores, keys, ...) and only takes effect after a restart.
+type Live struct {
+	current atomic.Pointer[Config]
+
+	mu        sync.Mutex
+	listeners []func(*Config)
+}
+
+func NewLive(cfg *Config) *Live {
+	live := &Live{}
+	live.current.Store(cfg)
+	return live
+}
+
+// Current returns the configuration in effect. Callers must not modify it.
+func (l *Live) Current() *Config {
+	return l.current.Load()
+}
+
+// OnReload registers fn to be called with the new configuration after every
+// successful reload
+func (l *Live) OnReload(fn func(*Config)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.listeners = append(l.listeners, fn)
+}
+
+// Reload loads the configuration again and applies the verification policy,
+// rate limits and API key TTL. It returns the sections with other changes,
+// which were ignored. On error the running configuration is left untouched.
+func (l *Live) Reload() ([]string, error) {
+	next, err := Load()
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	applied := *l.Current()
+	applied.KYC = next.KYC
+	applied.RateLimit = next.RateLimit
+	applied.JWT.TTL = next.JWT.TTL
+
+	ignored := changedSections(&applied, next)
+	l.current.Store(&applied)
+	for _, fn := range l.listeners {
+		fn(&applied)
+	}
+
+	return ignored, nil
+}
+
+// changedSections names the top level sections that differ between a and b
+func changedSections(a, b *Config) []string {
+	var changed []string
+	va, vb := reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem()
+	for i := 0; i < va.NumField(); i++ {
+		if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			changed = append(changed, va.Type().Field(i).Tag.Get("yaml"))
+		}
+	}
+	return changed
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// liveConfigFile is a configuration file with fixed secrets, so reloading it
+// only changes what the test changes
+const liveConfigFile = `
+environment: development
+verifications:
+  encryption_key: 0123456789abcdef0123456789abcdef
+jwt:
+  secret: 0123456789abcdef0123456789abcdef
+  ttl: %s
+server:
+  port: "%s"
+rate_limit:
+  max: %d
+kyc:
+  min_age: %d
+`
+
+func writeLiveConfig(t *testing.T, path, ttl, port string, rateLimit, minAge int) {
+	t.Helper()
+
+	data := []byte(fmt.Sprintf(liveConfigFile, ttl, port, rateLimit, minAge))
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newLiveTest(t *testing.T) (*Live, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv("CONFIG_FILE", path)
+	writeLiveConfig(t, path, "24h", "3001", 10, 18)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	return NewLive(cfg), path
+}
+
+func TestLiveReloadAppliesRuntimeSettings(t *testing.T) {
+	live, path := newLiveTest(t)
+	var notified *Config
+	live.OnReload(func(cfg *Config) { notified = cfg })
+
+	writeLiveConfig(t, path, "48h", "4000", 20, 21)
+	ignored, err := live.Reload()
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	cfg := live.Current()
+	if cfg.KYC.MinAge != 21 || cfg.RateLimit.Max != 20 || cfg.JWT.TTL != 48*time.Hour {
+		t.Errorf("Current() = kyc %+v, rate limit %+v, jwt ttl %v, want the reloaded values", cfg.KYC, cfg.RateLimit, cfg.JWT.TTL)
+	}
+	if cfg.Server.Port != "3001" {
+		t.Errorf("Server.Port = %q, want the port the server started with", cfg.Server.Port)
+	}
+	if !slices.Equal(ignored, []string{"server"}) {
+		t.Errorf("Reload() ignored = %v, want [server]", ignored)
+	}
+	if notified != cfg {
+		t.Error("OnReload listener was not called with the applied configuration")
+	}
+}
+
+func TestLiveReloadKeepsConfigOnError(t *testing.T) {
+	live, path := newLiveTest(t)
+	before := live.Current()
+	live.OnReload(func(*Config) { t.Error("OnReload listener called for an invalid configuration") })
+
+	writeLiveConfig(t, path, "48h", "3001", 20, -1)
+	if _, err := live.Reload(); err == nil {
+		t.Fatal("Reload() succeeded, want the invalid min_age reported")
+	}
+
+	if err := os.WriteFile(path, []byte("kyc: [not, a, map"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := live.Reload(); err == nil {
+		t.Fatal("Reload() succeeded, want the malformed file reported")
+	}
+
+	if live.Current() != before {
+		t.Error("Current() changed, want the running configuration kept")
+	}
+}
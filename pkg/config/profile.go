@@ -0,0 +1,153 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+)
+
+// DefaultProfileName names the policy made of the top level kyc settings
+const DefaultProfileName = "default"
+
+// OptionalChecks are the checks a profile may disable. Face quality checks
+// are relaxed through their thresholds instead.
+var OptionalChecks = []string{
+	"document_validity",
+	"mrz",
+	"minimum_age",
+	"name_match",
+	"date_of_birth_match",
+}
+
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ProfileConfig is a named verification profile, e.g. "low-risk" or
+// "regulated". Settings it leaves out are taken from the kyc section.
+type ProfileConfig struct {
+	MinAge               *int           `yaml:"min_age" toml:"min_age"`
+	MinAgeByJurisdiction map[string]int `yaml:"min_age_by_jurisdiction" toml:"min_age_by_jurisdiction"`
+	MinConfidence        *float32       `yaml:"min_confidence" toml:"min_confidence"`
+	MinBrightness        *float32       `yaml:"min_brightness" toml:"min_brightness"`
+	MinSharpness         *float32       `yaml:"min_sharpness" toml:"min_sharpness"`
+	MinSimilarity        *float32       `yaml:"min_similarity" toml:"min_similarity"`
+	// DisabledChecks are OptionalChecks that are not run under this profile
+	DisabledChecks []string `yaml:"disabled_checks" toml:"disabled_checks"`
+}
+
+// Policy is the resolved set of rules a verification runs under
+type Policy struct {
+	// Profile is the name of the profile the policy was resolved from
+	Profile              string
+	MinAge               int
+	MinAgeByJurisdiction map[string]int
+	Face                 FaceCriteria
+	DisabledChecks       []string
+}
+
+// Disables reports whether the policy skips the named check
+func (p Policy) Disables(check string) bool {
+	return slices.Contains(p.DisabledChecks, check)
+}
+
+// HasProfile reports whether name can be passed to Policy
+func (k KYCConfig) HasProfile(name string) bool {
+	if name == DefaultProfileName {
+		return true
+	}
+	_, ok := k.Profiles[name]
+	return ok
+}
+
+// Policy resolves the named profile on top of the kyc settings. An empty
+// name selects DefaultProfile.
+func (k KYCConfig) Policy(name string) (Policy, error) {
+	if name == "" {
+		name = k.DefaultProfile
+	}
+	if name == "" {
+		name = DefaultProfileName
+	}
+
+	policy := Policy{
+		Profile:              name,
+		MinAge:               k.MinAge,
+		MinAgeByJurisdiction: k.MinAgeByJurisdiction,
+		Face:                 k.Face,
+	}
+	if name == DefaultProfileName {
+		return policy, nil
+	}
+
+	profile, ok := k.Profiles[name]
+	if !ok {
+		return Policy{}, fmt.Errorf("unknown verification profile %q", name)
+	}
+	if profile.MinAge != nil {
+		policy.MinAge = *profile.MinAge
+	}
+	if profile.MinAgeByJurisdiction != nil {
+		policy.MinAgeByJurisdiction = profile.MinAgeByJurisdiction
+	}
+	if profile.MinConfidence != nil {
+		policy.Face.MinConfidence = *profile.MinConfidence
+	}
+	if profile.MinBrightness != nil {
+		policy.Face.MinBrightness = *profile.MinBrightness
+	}
+	if profile.MinSharpness != nil {
+		policy.Face.MinSharpness = *profile.MinSharpness
+	}
+	if profile.MinSimilarity != nil {
+		policy.Face.MinSimilarity = *profile.MinSimilarity
+	}
+	policy.DisabledChecks = profile.DisabledChecks
+	return policy, nil
+}
+
+// validateProfiles checks profile names, checks and thresholds
+func (k KYCConfig) validateProfiles() []error {
+	var errs []error
+	problem := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if k.DefaultProfile != "" && !k.HasProfile(k.DefaultProfile) {
+		problem("KYC_DEFAULT_PROFILE %q is not a configured profile", k.DefaultProfile)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(k.Profiles)) {
+		profile := k.Profiles[name]
+		if name == DefaultProfileName {
+			problem("profile name %q is reserved for the kyc settings", DefaultProfileName)
+		} else if !profileNamePattern.MatchString(name) {
+			problem("invalid profile name %q: use up to 64 letters, digits, '-' or '_'", name)
+		}
+		if profile.MinAge != nil && *profile.MinAge < 0 {
+			problem("profile %q: min_age must not be negative", name)
+		}
+		for _, conflict := range jurisdictionConflicts(profile.MinAgeByJurisdiction) {
+			problem("profile %q: min_age_by_jurisdiction lists the same jurisdiction as %s", name, conflict)
+		}
+		for _, threshold := range []struct {
+			field string
+			score *float32
+		}{
+			{"min_confidence", profile.MinConfidence},
+			{"min_brightness", profile.MinBrightness},
+			{"min_sharpness", profile.MinSharpness},
+			{"min_similarity", profile.MinSimilarity},
+		} {
+			if threshold.score != nil && (*threshold.score < 0 || *threshold.score > 100) {
+				problem("profile %q: %s must be between 0 and 100, got %.2f", name, threshold.field, *threshold.score)
+			}
+		}
+		for _, check := range profile.DisabledChecks {
+			if !slices.Contains(OptionalChecks, check) {
+				problem("profile %q: check %q cannot be disabled, expected one of %v", name, check, OptionalChecks)
+			}
+		}
+	}
+
+	return errs
+}
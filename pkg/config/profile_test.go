@@ -0,0 +1,62 @@
+package config
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPolicy(t *testing.T) {
+	minAge, similarity := 21, float32(95)
+	kyc := Default().KYC
+	kyc.Profiles = map[string]ProfileConfig{
+		"strict":  {MinAge: &minAge, MinSimilarity: &similarity},
+		"lenient": {DisabledChecks: []string{"name_match", "mrz"}},
+	}
+
+	tests := []struct {
+		name           string
+		defaultProfile string
+		profile        string
+		wantProfile    string
+		wantMinAge     int
+		wantSimilarity float32
+		wantDisabled   []string
+		wantErr        bool
+	}{
+		{"kyc settings", "", "", DefaultProfileName, kyc.MinAge, kyc.Face.MinSimilarity, nil, false},
+		{"default profile", "strict", "", "strict", minAge, similarity, nil, false},
+		{"chosen profile", "", "strict", "strict", minAge, similarity, nil, false},
+		{"chosen over default profile", "strict", "lenient", "lenient", kyc.MinAge, kyc.Face.MinSimilarity, []string{"name_match", "mrz"}, false},
+		{"kyc settings over default profile", "strict", DefaultProfileName, DefaultProfileName, kyc.MinAge, kyc.Face.MinSimilarity, nil, false},
+		{"unknown profile", "", "relaxed", "", 0, 0, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := kyc
+			k.DefaultProfile = tt.defaultProfile
+
+			policy, err := k.Policy(tt.profile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Policy(%q) error = %v, want error %v", tt.profile, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if policy.Profile != tt.wantProfile || policy.MinAge != tt.wantMinAge || policy.Face.MinSimilarity != tt.wantSimilarity {
+				t.Errorf("Policy(%q) = %+v, want profile %q, min age %d and similarity %.0f",
+					tt.profile, policy, tt.wantProfile, tt.wantMinAge, tt.wantSimilarity)
+			}
+			// Thresholds the profile leaves out come from the kyc settings
+			if policy.Face.MinConfidence != kyc.Face.MinConfidence {
+				t.Errorf("Policy(%q) MinConfidence = %.0f, want %.0f", tt.profile, policy.Face.MinConfidence, kyc.Face.MinConfidence)
+			}
+			for _, check := range OptionalChecks {
+				want := slices.Contains(tt.wantDisabled, check)
+				if got := policy.Disables(check); got != want {
+					t.Errorf("Policy(%q).Disables(%q) = %v, want %v", tt.profile, check, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// minSecretLength is the shortest accepted HMAC, admin or webhook secret
+const minSecretLength = 32
+
+// maxRetryFailures is the most failures the attempt history can count, see
+// models.MaxAttemptHistory
+const maxRetryFailures = 50
+
+// leakedJWTSecret was the built-in default JWT secret of earlier releases. It
+// is public, so tokens signed with it must never be accepted.
+const leakedJWTSecret = "yqKmE7cB7OWpouhuR/x/11HMjx/0Ki5cwwN756K2/dM="
+
+// Validate checks the configuration for inconsistent and, in production,
+// insecure settings. Every problem found is reported, joined into one error.
+func (c *Config) Validate() error {
+	var errs []error
+	problem := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Environment != EnvProduction && c.Environment != EnvDevelopment {
+		problem("invalid APP_ENV %q: expected %q or %q", c.Environment, EnvProduction, EnvDevelopment)
+	}
+
+	if c.Provider.Name != ProviderAWS && c.Provider.Name != ProviderFake {
+		problem("invalid PROVIDER %q: expected %q or %q", c.Provider.Name, ProviderAWS, ProviderFake)
+	}
+
+	switch c.Attempts.Store {
+	case "", AttemptStoreBolt:
+	case AttemptStoreDynamoDB:
+		if c.Provider.Name == ProviderFake {
+			problem("ATTEMPT_STORE %q requires PROVIDER %q", AttemptStoreDynamoDB, ProviderAWS)
+		}
+	default:
+		problem("invalid ATTEMPT_STORE %q: expected %q or %q", c.Attempts.Store, AttemptStoreDynamoDB, AttemptStoreBolt)
+	}
+
+	switch c.Verifications.Store {
+	case "", AttemptStoreBolt:
+	case AttemptStoreDynamoDB:
+		if c.Provider.Name == ProviderFake {
+			problem("VERIFICATION_STORE %q requires PROVIDER %q", AttemptStoreDynamoDB, ProviderAWS)
+		}
+	default:
+		problem("invalid VERIFICATION_STORE %q: expected %q or %q", c.Verifications.Store, AttemptStoreDynamoDB, AttemptStoreBolt)
+	}
+	if c.Verifications.EncryptionKey == "" {
+		problem("RECORD_ENCRYPTION_KEY is required")
+	}
+
+	if (c.AWS.AccessKeyID == "") != (c.AWS.SecretAccessKey == "") {
+		problem("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set together")
+	}
+	if c.AWS.AssumeRoleARN == "" && c.AWS.AssumeRoleExternalID != "" {
+		problem("AWS_ASSUME_ROLE_EXTERNAL_ID requires AWS_ASSUME_ROLE_ARN")
+	}
+	if c.AWS.EndpointURL != "" {
+		if u, err := url.Parse(c.AWS.EndpointURL); err != nil || u.Scheme == "" || u.Host == "" {
+			problem("invalid AWS_ENDPOINT_URL %q", c.AWS.EndpointURL)
+		}
+	}
+
+	switch c.Attempts.OnFailure {
+	case AttemptFailureFail:
+	case AttemptFailureRetry:
+		retry := c.Attempts.Retry
+		if retry.MaxAttempts <= 0 || retry.InitialBackoff <= 0 || retry.MaxBackoff <= 0 || retry.MaxPending <= 0 {
+			problem("ATTEMPT_RETRY_MAX_ATTEMPTS, ATTEMPT_RETRY_INITIAL_BACKOFF, ATTEMPT_RETRY_MAX_BACKOFF and ATTEMPT_RETRY_MAX_PENDING must be positive")
+		}
+	default:
+		problem("invalid ATTEMPT_ON_FAILURE %q: expected %q or %q", c.Attempts.OnFailure, AttemptFailureFail, AttemptFailureRetry)
+	}
+	if c.Attempts.Lease <= 0 {
+		problem("ATTEMPT_LEASE must be positive")
+	}
+
+	_, activeSecret := c.JWT.Keys[c.JWT.ActiveKeyID]
+	_, activePrivate := c.JWT.PrivateKeyFiles[c.JWT.ActiveKeyID]
+	switch {
+	case len(c.JWT.Keys) == 0 && len(c.JWT.PrivateKeyFiles) == 0:
+		problem("JWT_SECRET, JWT_KEYS or JWT_PRIVATE_KEYS is required")
+	case !activeSecret && !activePrivate:
+		problem("JWT_ACTIVE_KEY_ID %q is not one of the JWT_KEYS or JWT_PRIVATE_KEYS", c.JWT.ActiveKeyID)
+	}
+	if c.JWT.TTL <= 0 {
+		problem("JWT_TTL must be positive")
+	}
+
+	if c.Jobs.Retention <= 0 {
+		problem("JOB_RETENTION must be positive")
+	}
+
+	if c.RateLimit.Max <= 0 || c.RateLimit.TenantMax <= 0 || c.RateLimit.Window <= 0 {
+		problem("RATE_LIMIT_MAX, RATE_LIMIT_TENANT_MAX and RATE_LIMIT_WINDOW must be positive")
+	}
+
+	errs = append(errs, c.KYC.validateProfiles()...)
+	if c.KYC.MinAge < 0 {
+		problem("MIN_AGE must not be negative")
+	}
+	for _, conflict := range jurisdictionConflicts(c.KYC.MinAgeByJurisdiction) {
+		problem("MIN_AGE_BY_JURISDICTION lists the same jurisdiction as %s", conflict)
+	}
+	retry := c.KYC.Retry
+	if retry.MaxFailures < 0 || retry.MaxFailures > maxRetryFailures {
+		problem("KYC_MAX_FAILED_ATTEMPTS must be between 0 and %d", maxRetryFailures)
+	}
+	if retry.MaxFailures > 0 && retry.Window <= 0 {
+		problem("KYC_ATTEMPT_WINDOW must be positive")
+	}
+	if retry.Lockout < 0 {
+		problem("KYC_ATTEMPT_LOCKOUT must not be negative")
+	}
+	face := c.KYC.Face
+	for _, threshold := range []struct {
+		name  string
+		score float32
+	}{
+		{"FACE_MIN_CONFIDENCE", face.MinConfidence},
+		{"FACE_MIN_BRIGHTNESS", face.MinBrightness},
+		{"FACE_MIN_SHARPNESS", face.MinSharpness},
+		{"FACE_MIN_SIMILARITY", face.MinSimilarity},
+	} {
+		if threshold.score < 0 || threshold.score > 100 {
+			problem("%s must be between 0 and 100, got %.2f", threshold.name, threshold.score)
+		}
+	}
+
+	for kid, secret := range c.JWT.Keys {
+		if secret == leakedJWTSecret {
+			problem("JWT signing key %q is the publicly known former default secret", kid)
+		}
+	}
+
+	if c.Environment == EnvProduction {
+		errs = append(errs, c.validateProduction())
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateProduction rejects settings that are only acceptable during
+// development
+func (c *Config) validateProduction() error {
+	var errs []error
+	problem := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Provider.Name == ProviderFake {
+		problem("PROVIDER %q is not allowed in production", ProviderFake)
+	}
+
+	if c.Provider.Name == ProviderAWS {
+		if c.AWS.EndpointURL != "" && !strings.HasPrefix(c.AWS.EndpointURL, "https://") {
+			problem("AWS_ENDPOINT_URL must use https in production")
+		}
+		if c.Attempts.Store != AttemptStoreBolt && c.Attempts.Table == "" {
+			problem("KYC_RECORD is required when attempts are stored in DynamoDB")
+		}
+		if c.Verifications.Store != AttemptStoreBolt && c.Verifications.Table == "" {
+			problem("VERIFICATION_TABLE is required when verification records are stored in DynamoDB")
+		}
+	}
+
+	for kid, secret := range c.JWT.Keys {
+		if len(secret) < minSecretLength {
+			problem("JWT signing key %q must be at least %d characters", kid, minSecretLength)
+		}
+	}
+	if c.JWT.AdminToken != "" && len(c.JWT.AdminToken) < minSecretLength {
+		problem("ADMIN_TOKEN must be at least %d characters", minSecretLength)
+	}
+	if c.Verifications.EncryptionKey != "" && len(c.Verifications.EncryptionKey) < minSecretLength {
+		problem("RECORD_ENCRYPTION_KEY must be at least %d characters", minSecretLength)
+	}
+	if c.Webhook.Secret != "" && len(c.Webhook.Secret) < minSecretLength {
+		problem("WEBHOOK_SECRET must be at least %d characters", minSecretLength)
+	}
+	if c.Webhook.AllowPrivateNetworks {
+		problem("WEBHOOK_ALLOW_PRIVATE_NETWORKS is not allowed in production")
+	}
+
+	for _, origin := range strings.Split(c.Server.CORSOrigins, ",") {
+		if strings.TrimSpace(origin) == "*" {
+			problem("CORS_ALLOWED_ORIGINS must list origins explicitly, \"*\" is not allowed in production")
+		}
+	}
+
+	return errors.Join(errs...)
+}
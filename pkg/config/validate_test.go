@@ -0,0 +1,130 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+// validConfig returns a production configuration that passes Validate
+func validConfig() *Config {
+	c := Default()
+	c.JWT.Keys = map[string]string{"default": testSecret}
+	c.JWT.ActiveKeyID = "default"
+	c.Verifications.EncryptionKey = testSecret
+	c.Attempts.Table = "kyc-attempts"
+	c.Verifications.Table = "kyc-verifications"
+	c.Server.CORSOrigins = "https://app.example.com"
+	return c
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(c *Config)
+		want   string
+	}{
+		{"valid", func(c *Config) {}, ""},
+		{"invalid environment", func(c *Config) { c.Environment = "staging" }, `invalid APP_ENV "staging"`},
+		{"invalid provider", func(c *Config) { c.Provider.Name = "gcp" }, `invalid PROVIDER "gcp"`},
+		{"dynamodb attempts with fake provider", func(c *Config) {
+			c.Environment = EnvDevelopment
+			c.Provider.Name = ProviderFake
+			c.Attempts.Store = AttemptStoreDynamoDB
+		}, "ATTEMPT_STORE \"dynamodb\" requires PROVIDER"},
+		{"missing record encryption key", func(c *Config) { c.Verifications.EncryptionKey = "" }, "RECORD_ENCRYPTION_KEY is required"},
+		{"access key without secret", func(c *Config) { c.AWS.AccessKeyID = "AKIA" }, "must be set together"},
+		{"external ID without role", func(c *Config) { c.AWS.AssumeRoleExternalID = "ext" }, "requires AWS_ASSUME_ROLE_ARN"},
+		{"relative endpoint", func(c *Config) { c.AWS.EndpointURL = "localstack" }, "invalid AWS_ENDPOINT_URL"},
+		{"invalid attempt failure mode", func(c *Config) { c.Attempts.OnFailure = "ignore" }, "invalid ATTEMPT_ON_FAILURE"},
+		{"zero attempt lease", func(c *Config) { c.Attempts.Lease = 0 }, "ATTEMPT_LEASE must be positive"},
+		{"no signing keys", func(c *Config) { c.JWT.Keys = nil }, "JWT_SECRET, JWT_KEYS or JWT_PRIVATE_KEYS is required"},
+		{"unknown active key", func(c *Config) { c.JWT.ActiveKeyID = "next" }, `JWT_ACTIVE_KEY_ID "next"`},
+		{"leaked JWT secret", func(c *Config) { c.JWT.Keys["default"] = leakedJWTSecret }, "publicly known former default secret"},
+		{"zero JWT TTL", func(c *Config) { c.JWT.TTL = 0 }, "JWT_TTL must be positive"},
+		{"zero job retention", func(c *Config) { c.Jobs.Retention = 0 }, "JOB_RETENTION must be positive"},
+		{"zero rate limit", func(c *Config) { c.RateLimit.TenantMax = 0 }, "RATE_LIMIT_MAX"},
+		{"negative minimum age", func(c *Config) { c.KYC.MinAge = -1 }, "MIN_AGE must not be negative"},
+		{"unknown default profile", func(c *Config) { c.KYC.DefaultProfile = "strict" }, `KYC_DEFAULT_PROFILE "strict" is not a configured profile`},
+		{"too many failures", func(c *Config) { c.KYC.Retry.MaxFailures = maxRetryFailures + 1 }, "KYC_MAX_FAILED_ATTEMPTS must be between 0 and 50"},
+		{"zero attempt window", func(c *Config) { c.KYC.Retry.Window = 0 }, "KYC_ATTEMPT_WINDOW must be positive"},
+		{"negative lockout", func(c *Config) { c.KYC.Retry.Lockout = -time.Hour }, "KYC_ATTEMPT_LOCKOUT must not be negative"},
+		{"duplicate jurisdiction", func(c *Config) { c.KYC.MinAgeByJurisdiction = map[string]int{"MS": 21, "ms": 21} }, "MIN_AGE_BY_JURISDICTION lists the same jurisdiction"},
+		{"face score out of range", func(c *Config) { c.KYC.Face.MinSimilarity = 101 }, "FACE_MIN_SIMILARITY must be between 0 and 100"},
+
+		{"fake provider in production", func(c *Config) { c.Provider.Name = ProviderFake }, `PROVIDER "fake" is not allowed in production`},
+		{"plain http endpoint in production", func(c *Config) { c.AWS.EndpointURL = "http://localstack:4566" }, "AWS_ENDPOINT_URL must use https"},
+		{"missing attempts table", func(c *Config) { c.Attempts.Table = "" }, "KYC_RECORD is required"},
+		{"short JWT secret", func(c *Config) { c.JWT.Keys["default"] = "short" }, `JWT signing key "default" must be at least 32 characters`},
+		{"short admin token", func(c *Config) { c.JWT.AdminToken = "short" }, "ADMIN_TOKEN must be at least 32 characters"},
+		{"short webhook secret", func(c *Config) { c.Webhook.Secret = "short" }, "WEBHOOK_SECRET must be at least 32 characters"},
+		{"private webhooks in production", func(c *Config) { c.Webhook.AllowPrivateNetworks = true }, "WEBHOOK_ALLOW_PRIVATE_NETWORKS is not allowed in production"},
+		{"any CORS origin in production", func(c *Config) { c.Server.CORSOrigins = "https://app.example.com, *" }, "CORS_ALLOWED_ORIGINS must list origins explicitly"},
+
+		{"development allows insecure settings", func(c *Config) {
+			c.Environment = EnvDevelopment
+			c.Provider.Name = ProviderFake
+			c.JWT.Keys["default"] = "short"
+			c.Webhook.AllowPrivateNetworks = true
+			c.Server.CORSOrigins = "*"
+		}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := validConfig()
+			tt.mutate(c)
+			err := c.Validate()
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("Validate() error = %v, want none", err)
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Errorf("Validate() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	c := validConfig()
+	c.Attempts.Lease = 0
+	c.KYC.MinAge = -1
+	c.Webhook.Secret = "short"
+
+	err := c.Validate()
+	if err == nil {
+		t.Fatal("Validate() succeeded, want errors")
+	}
+	for _, want := range []string{"ATTEMPT_LEASE", "MIN_AGE", "WEBHOOK_SECRET"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() error = %v, want it to mention %s", err, want)
+		}
+	}
+}
+
+func TestValidateReportsRetryAndFaceProblemsInOrder(t *testing.T) {
+	c := validConfig()
+	c.KYC.Retry = RetryConfig{MaxFailures: 3, Lockout: -time.Hour}
+	c.KYC.Face.MinConfidence = 101
+	c.KYC.Face.MinSimilarity = -1
+
+	err := c.Validate()
+	if err == nil {
+		t.Fatal("Validate() succeeded, want errors")
+	}
+	want := []string{"KYC_ATTEMPT_WINDOW", "KYC_ATTEMPT_LOCKOUT", "FACE_MIN_CONFIDENCE", "FACE_MIN_SIMILARITY"}
+	last := -1
+	for _, name := range want {
+		i := strings.Index(err.Error(), name)
+		if i < 0 {
+			t.Errorf("Validate() error = %v, want it to mention %s", err, name)
+			continue
+		}
+		if i < last {
+			t.Errorf("Validate() error = %v, want %v in that order", err, want)
+		}
+		last = i
+	}
+}
@@ -0,0 +1,327 @@
+// Package mrz parses and validates ICAO 9303 machine readable zones found on
+// passports (TD3), visas and older ID cards (TD2) and ID cards (TD1).
+package mrz
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Format identifies the MRZ layout
+type Format string
+
+const (
+	TD1 Format = "TD1" // 3 lines of 30 characters
+	TD2 Format = "TD2" // 2 lines of 36 characters
+	TD3 Format = "TD3" // 2 lines of 44 characters
+)
+
+// Field names reported in Check and in comparisons with the visual zone
+const (
+	FieldName           = "name"
+	FieldDocumentNumber = "document_number"
+	FieldDateOfBirth    = "date_of_birth"
+	FieldExpiryDate     = "expiry_date"
+	FieldOptionalData   = "optional_data"
+	FieldComposite      = "composite"
+)
+
+var ErrInvalidFormat = errors.New("mrz: unrecognized format")
+
+// Check is the result of validating a single check digit
+type Check struct {
+	Field string
+	Valid bool
+}
+
+// MRZ holds the decoded fields of a machine readable zone. Dates are kept in
+// their encoded YYMMDD form; use BirthDate and ExpiryDate to resolve them.
+type MRZ struct {
+	Format         Format
+	DocumentCode   string
+	IssuingState   string
+	Surname        string
+	GivenNames     string
+	DocumentNumber string
+	Nationality    string
+	DateOfBirth    string
+	Sex            string
+	Expiry         string
+	OptionalData   string
+	Checks         []Check
+}
+
+// Valid reports whether every check digit in the MRZ is correct
+func (m *MRZ) Valid() bool {
+	for _, c := range m.Checks {
+		if !c.Valid {
+			return false
+		}
+	}
+	return true
+}
+
+// FailedChecks returns the names of the fields whose check digit is wrong
+func (m *MRZ) FailedChecks() []string {
+	var failed []string
+	for _, c := range m.Checks {
+		if !c.Valid {
+			failed = append(failed, c.Field)
+		}
+	}
+	return failed
+}
+
+// BirthDate resolves the two-digit birth year, assuming the holder was not
+// born in the future relative to now
+func (m *MRZ) BirthDate(now time.Time) (time.Time, error) {
+	t, err := parseDate(m.DateOfBirth, 2000)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if t.After(now) {
+		t = t.AddDate(-100, 0, 0)
+	}
+	return t, nil
+}
+
+// ExpiryDate resolves the two-digit expiry year into the 21st century
+func (m *MRZ) ExpiryDate() (time.Time, error) {
+	return parseDate(m.Expiry, 2000)
+}
+
+func parseDate(yymmdd string, century int) (time.Time, error) {
+	t, err := time.Parse("060102", yymmdd)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("mrz: invalid date %q", yymmdd)
+	}
+	return time.Date(century+t.Year()%100, t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+}
+
+// Parse decodes an MRZ. Lines may be separated by newlines; if they are not,
+// the format is inferred from the total length. Whitespace inside lines is
+// ignored.
+func Parse(raw string) (*MRZ, error) {
+	lines := splitLines(raw)
+
+	switch {
+	case len(lines) == 3 && allLength(lines, 30):
+		return parseTD1(lines), nil
+	case len(lines) == 2 && allLength(lines, 36):
+		return parseTD2(lines), nil
+	case len(lines) == 2 && allLength(lines, 44):
+		return parseTD3(lines), nil
+	}
+
+	return nil, ErrInvalidFormat
+}
+
+func splitLines(raw string) []string {
+	raw = strings.ToUpper(raw)
+
+	var lines []string
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.Join(strings.Fields(line), "")
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	if len(lines) == 1 {
+		joined := lines[0]
+		switch len(joined) {
+		case 90:
+			return []string{joined[:30], joined[30:60], joined[60:]}
+		case 72:
+			return []string{joined[:36], joined[36:]}
+		case 88:
+			return []string{joined[:44], joined[44:]}
+		}
+	}
+
+	return lines
+}
+
+func allLength(lines []string, n int) bool {
+	for _, line := range lines {
+		if len(line) != n {
+			return false
+		}
+	}
+	return true
+}
+
+func parseTD1(lines []string) *MRZ {
+	l1, l2, l3 := lines[0], lines[1], lines[2]
+
+	number, numberCheck, optional := splitDocumentNumber(l1[5:14], l1[14], l1[15:30])
+	surname, given := parseName(l3)
+
+	m := &MRZ{
+		Format:         TD1,
+		DocumentCode:   trimFiller(l1[0:2]),
+		IssuingState:   trimFiller(l1[2:5]),
+		DocumentNumber: number,
+		DateOfBirth:    l2[0:6],
+		Sex:            trimFiller(l2[7:8]),
+		Expiry:         l2[8:14],
+		Nationality:    trimFiller(l2[15:18]),
+		OptionalData:   trimFiller(optional + l2[18:29]),
+		Surname:        surname,
+		GivenNames:     given,
+	}
+
+	m.Checks = []Check{
+		{FieldDocumentNumber, verify(numberCheck.data, numberCheck.digit)},
+		{FieldDateOfBirth, verify(l2[0:6], l2[6])},
+		{FieldExpiryDate, verify(l2[8:14], l2[14])},
+		{FieldComposite, verify(l1[5:30]+l2[0:7]+l2[8:15]+l2[18:29], l2[29])},
+	}
+
+	return m
+}
+
+func parseTD2(lines []string) *MRZ {
+	l1, l2 := lines[0], lines[1]
+
+	number, numberCheck, optional := splitDocumentNumber(l2[0:9], l2[9], l2[28:35])
+	surname, given := parseName(l1[5:36])
+
+	m := &MRZ{
+		Format:         TD2,
+		DocumentCode:   trimFiller(l1[0:2]),
+		IssuingState:   trimFiller(l1[2:5]),
+		Surname:        surname,
+		GivenNames:     given,
+		DocumentNumber: number,
+		Nationality:    trimFiller(l2[10:13]),
+		DateOfBirth:    l2[13:19],
+		Sex:            trimFiller(l2[20:21]),
+		Expiry:         l2[21:27],
+		OptionalData:   trimFiller(optional),
+	}
+
+	m.Checks = []Check{
+		{FieldDocumentNumber, verify(numberCheck.data, numberCheck.digit)},
+		{FieldDateOfBirth, verify(l2[13:19], l2[19])},
+		{FieldExpiryDate, verify(l2[21:27], l2[27])},
+		{FieldComposite, verify(l2[0:10]+l2[13:20]+l2[21:35], l2[35])},
+	}
+
+	return m
+}
+
+func parseTD3(lines []string) *MRZ {
+	l1, l2 := lines[0], lines[1]
+
+	surname, given := parseName(l1[5:44])
+
+	m := &MRZ{
+		Format:         TD3,
+		DocumentCode:   trimFiller(l1[0:2]),
+		IssuingState:   trimFiller(l1[2:5]),
+		Surname:        surname,
+		GivenNames:     given,
+		DocumentNumber: trimFiller(l2[0:9]),
+		Nationality:    trimFiller(l2[10:13]),
+		DateOfBirth:    l2[13:19],
+		Sex:            trimFiller(l2[20:21]),
+		Expiry:         l2[21:27],
+		OptionalData:   trimFiller(l2[28:42]),
+	}
+
+	m.Checks = []Check{
+		{FieldDocumentNumber, verify(l2[0:9], l2[9])},
+		{FieldDateOfBirth, verify(l2[13:19], l2[19])},
+		{FieldExpiryDate, verify(l2[21:27], l2[27])},
+		{FieldOptionalData, verifyOptional(l2[28:42], l2[42])},
+		{FieldComposite, verify(l2[0:10]+l2[13:20]+l2[21:43], l2[43])},
+	}
+
+	return m
+}
+
+type checkedData struct {
+	data  string
+	digit byte
+}
+
+// splitDocumentNumber handles document numbers longer than nine characters.
+// ICAO 9303 signals this with a filler in the check digit position; the
+// number then continues in the optional data, followed by its check digit.
+func splitDocumentNumber(number string, check byte, optional string) (string, checkedData, string) {
+	if check != '<' {
+		return trimFiller(number), checkedData{number, check}, optional
+	}
+
+	end := strings.IndexByte(optional, '<')
+	if end < 0 {
+		end = len(optional)
+	}
+	if end == 0 {
+		return trimFiller(number), checkedData{number, check}, optional
+	}
+
+	overflow := optional[:end-1]
+	full := number + overflow
+	return trimFiller(full), checkedData{full, optional[end-1]}, optional[end:]
+}
+
+// parseName splits the name field into primary and secondary identifiers.
+// Name parts are separated by "<<" and words within a part by "<".
+func parseName(field string) (string, string) {
+	field = strings.TrimRight(field, "<")
+	surname, given, _ := strings.Cut(field, "<<")
+	return fillerToSpace(surname), fillerToSpace(given)
+}
+
+func fillerToSpace(s string) string {
+	return strings.Join(strings.FieldsFunc(s, func(r rune) bool { return r == '<' }), " ")
+}
+
+func trimFiller(s string) string {
+	return strings.TrimRight(s, "<")
+}
+
+func verify(data string, check byte) bool {
+	digit, ok := charValue(check)
+	if !ok || check > '9' {
+		return false
+	}
+	return CheckDigit(data) == digit
+}
+
+// verifyOptional validates the TD3 personal number check digit, which may be
+// a filler when the personal number is not used
+func verifyOptional(data string, check byte) bool {
+	if check == '<' && strings.Trim(data, "<") == "" {
+		return true
+	}
+	return verify(data, check)
+}
+
+// CheckDigit computes the ICAO 9303 check digit of data using the repeating
+// 7-3-1 weighting. Characters outside 0-9, A-Z and '<' count as zero.
+func CheckDigit(data string) int {
+	weights := [3]int{7, 3, 1}
+	sum := 0
+	for i := 0; i < len(data); i++ {
+		value, _ := charValue(data[i])
+		sum += value * weights[i%3]
+	}
+	return sum % 10
+}
+
+func charValue(c byte) (int, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0'), true
+	case c >= 'A' && c <= 'Z':
+		return int(c-'A') + 10, true
+	case c == '<':
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,159 @@
+package mrz
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// ICAO 9303 specimens
+const (
+	specimenTD1 = "I<UTOD231458907<<<<<<<<<<<<<<<\n7408122F1204159UTO<<<<<<<<<<<6\nERIKSSON<<ANNA<MARIA<<<<<<<<<<"
+	specimenTD2 = "I<UTOERIKSSON<<ANNA<MARIA<<<<<<<<<<<\nD231458907UTO7408122F1204159<<<<<<<6"
+	specimenTD3 = "P<UTOERIKSSON<<ANNA<MARIA<<<<<<<<<<<<<<<<<<<\nL898902C36UTO7408122F1204159ZE184226B<<<<<10"
+)
+
+func TestCheckDigit(t *testing.T) {
+	tests := []struct {
+		data string
+		want int
+	}{
+		{"L898902C3", 6},
+		{"740812", 2},
+		{"120415", 9},
+		{"ZE184226B<<<<<", 1},
+		{"D23145890", 7},
+		{"<<<<<<", 0},
+		{"", 0},
+	}
+
+	for _, tt := range tests {
+		if got := CheckDigit(tt.data); got != tt.want {
+			t.Errorf("CheckDigit(%q) = %d, want %d", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want MRZ
+	}{
+		{"TD1", specimenTD1, MRZ{
+			Format: TD1, DocumentCode: "I", IssuingState: "UTO", Surname: "ERIKSSON", GivenNames: "ANNA MARIA",
+			DocumentNumber: "D23145890", Nationality: "UTO", DateOfBirth: "740812", Sex: "F", Expiry: "120415",
+		}},
+		{"TD2", specimenTD2, MRZ{
+			Format: TD2, DocumentCode: "I", IssuingState: "UTO", Surname: "ERIKSSON", GivenNames: "ANNA MARIA",
+			DocumentNumber: "D23145890", Nationality: "UTO", DateOfBirth: "740812", Sex: "F", Expiry: "120415",
+		}},
+		{"TD3", specimenTD3, MRZ{
+			Format: TD3, DocumentCode: "P", IssuingState: "UTO", Surname: "ERIKSSON", GivenNames: "ANNA MARIA",
+			DocumentNumber: "L898902C3", Nationality: "UTO", DateOfBirth: "740812", Sex: "F", Expiry: "120415",
+			OptionalData: "ZE184226B",
+		}},
+		{"TD3 on one line in lower case", " p<utoeriksson<<anna<maria<<<<<<<<<<<<<<<<<<<L898902C36UTO7408122F1204159ZE184226B<<<<<10 ", MRZ{
+			Format: TD3, DocumentCode: "P", IssuingState: "UTO", Surname: "ERIKSSON", GivenNames: "ANNA MARIA",
+			DocumentNumber: "L898902C3", Nationality: "UTO", DateOfBirth: "740812", Sex: "F", Expiry: "120415",
+			OptionalData: "ZE184226B",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.raw)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !got.Valid() {
+				t.Errorf("FailedChecks() = %v, want none", got.FailedChecks())
+			}
+			got.Checks = nil
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInvalidFormat(t *testing.T) {
+	for _, raw := range []string{"", "not an MRZ", specimenTD3[:60]} {
+		if _, err := Parse(raw); err != ErrInvalidFormat {
+			t.Errorf("Parse(%q) error = %v, want %v", raw, err, ErrInvalidFormat)
+		}
+	}
+}
+
+func TestFailedChecks(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want []string
+	}{
+		{"document number", "P<UTOERIKSSON<<ANNA<MARIA<<<<<<<<<<<<<<<<<<<\nL898902C46UTO7408122F1204159ZE184226B<<<<<10", []string{FieldDocumentNumber, FieldComposite}},
+		{"date of birth", "P<UTOERIKSSON<<ANNA<MARIA<<<<<<<<<<<<<<<<<<<\nL898902C36UTO7408132F1204159ZE184226B<<<<<10", []string{FieldDateOfBirth, FieldComposite}},
+		{"expiry check digit", "P<UTOERIKSSON<<ANNA<MARIA<<<<<<<<<<<<<<<<<<<\nL898902C36UTO7408122F1204158ZE184226B<<<<<10", []string{FieldExpiryDate, FieldComposite}},
+		{"filler check digit", "P<UTOERIKSSON<<ANNA<MARIA<<<<<<<<<<<<<<<<<<<\nL898902C3<UTO7408122F1204159ZE184226B<<<<<10", []string{FieldDocumentNumber, FieldComposite}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := Parse(tt.raw)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got := m.FailedChecks(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FailedChecks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitDocumentNumber(t *testing.T) {
+	// A 12 character number continues in the optional data after a filler
+	// check digit, followed by its own check digit
+	number, check, optional := splitDocumentNumber("D23145890", '<', "7349<<<<<<<<<<<")
+	if number != "D23145890734" || check != (checkedData{"D23145890734", '9'}) || optional != "<<<<<<<<<<<" {
+		t.Errorf("splitDocumentNumber() = %q, %+v, %q", number, check, optional)
+	}
+
+	number, check, optional = splitDocumentNumber("D23145890", '7', "<<<<<<<<<<<<<<<")
+	if number != "D23145890" || check != (checkedData{"D23145890", '7'}) || optional != "<<<<<<<<<<<<<<<" {
+		t.Errorf("splitDocumentNumber() = %q, %+v, %q", number, check, optional)
+	}
+}
+
+func TestBirthDate(t *testing.T) {
+	now := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		dob     string
+		want    time.Time
+		wantErr bool
+	}{
+		{"740812", time.Date(1974, 8, 12, 0, 0, 0, 0, time.UTC), false},
+		{"101016", time.Date(2010, 10, 16, 0, 0, 0, 0, time.UTC), false},
+		{"261016", time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC), false},
+		{"261017", time.Date(1926, 10, 17, 0, 0, 0, 0, time.UTC), false},
+		{"991231", time.Date(1999, 12, 31, 0, 0, 0, 0, time.UTC), false},
+		{"741312", time.Time{}, true},
+		{"<<<<<<", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := (&MRZ{DateOfBirth: tt.dob}).BirthDate(now)
+		if (err != nil) != tt.wantErr || !got.Equal(tt.want) {
+			t.Errorf("BirthDate(%q) = %v, %v, want %v", tt.dob, got, err, tt.want)
+		}
+	}
+}
+
+func TestExpiryDate(t *testing.T) {
+	got, err := (&MRZ{Expiry: "120415"}).ExpiryDate()
+	if want := time.Date(2012, 4, 15, 0, 0, 0, 0, time.UTC); err != nil || !got.Equal(want) {
+		t.Errorf("ExpiryDate() = %v, %v, want %v", got, err, want)
+	}
+	if _, err := (&MRZ{Expiry: "12041"}).ExpiryDate(); err == nil {
+		t.Error("ExpiryDate() of a short date succeeded, want an error")
+	}
+}
@@ -0,0 +1,79 @@
+// Package seal encrypts and hashes sensitive values before they are stored.
+// Both keys are derived from a single secret, so rotating the secret makes
+// previously sealed values unreadable.
+package seal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+var ErrMalformed = errors.New("seal: malformed sealed value")
+
+// Sealer encrypts values with AES-256-GCM and hashes them with HMAC-SHA256
+type Sealer struct {
+	aead    cipher.AEAD
+	hashKey []byte
+}
+
+func New(secret string) (*Sealer, error) {
+	if secret == "" {
+		return nil, errors.New("seal: secret is empty")
+	}
+
+	block, err := aes.NewCipher(deriveKey(secret, "encryption"))
+	if err != nil {
+		return nil, fmt.Errorf("seal: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("seal: %w", err)
+	}
+
+	return &Sealer{aead: aead, hashKey: deriveKey(secret, "hash")}, nil
+}
+
+func deriveKey(secret, purpose string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("kyc-api/seal/" + purpose))
+	return mac.Sum(nil)
+}
+
+// Seal encrypts plaintext under a random nonce and returns the base64
+// encoded nonce and ciphertext
+func (s *Sealer) Seal(plaintext []byte) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("seal: failed to generate nonce: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(s.aead.Seal(nonce, nonce, plaintext, nil)), nil
+}
+
+// Open decrypts a value returned by Seal
+func (s *Sealer) Open(sealed string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < s.aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+	nonce, ciphertext := data[:s.aead.NonceSize()], data[s.aead.NonceSize():]
+	plaintext, err := s.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("seal: failed to decrypt: %w", err)
+	}
+	return plaintext, nil
+}
+
+// Hash returns a keyed hash of value, so equal values can be matched without
+// storing them
+func (s *Sealer) Hash(value string) string {
+	mac := hmac.New(sha256.New, s.hashKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,62 @@
+package seal
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	s, err := New("0123456789abcdef0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext := []byte("ERIKSSON ANNA MARIA")
+	sealed, err := s.Seal(plaintext)
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if again, _ := s.Seal(plaintext); again == sealed {
+		t.Error("Seal() is deterministic, want a random nonce per value")
+	}
+
+	opened, err := s.Open(sealed)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if !bytes.Equal(opened, plaintext) {
+		t.Errorf("Open() = %q, want %q", opened, plaintext)
+	}
+
+	other, _ := New("another secret")
+	if _, err := other.Open(sealed); err == nil {
+		t.Error("Open() with another secret succeeded")
+	}
+	for _, malformed := range []string{"", "not base64!", "c2hvcnQ"} {
+		if _, err := s.Open(malformed); !errors.Is(err, ErrMalformed) {
+			t.Errorf("Open(%q) error = %v, want %v", malformed, err, ErrMalformed)
+		}
+	}
+}
+
+func TestHash(t *testing.T) {
+	s, _ := New("0123456789abcdef0123456789abcdef")
+	other, _ := New("another secret")
+
+	if s.Hash("L898902C3") != s.Hash("L898902C3") {
+		t.Error("Hash() is not stable")
+	}
+	if s.Hash("L898902C3") == s.Hash("L898902C4") {
+		t.Error("Hash() of different values is equal")
+	}
+	if s.Hash("L898902C3") == other.Hash("L898902C3") {
+		t.Error("Hash() does not depend on the secret")
+	}
+}
+
+func TestNewRejectsEmptySecret(t *testing.T) {
+	if _, err := New(""); err == nil {
+		t.Error("New(\"\") succeeded, want an error")
+	}
+}